@@ -0,0 +1,7 @@
+package main
+
+import "explorer/sqlite"
+
+func main() {
+	sqlite.RunExplorer()
+}
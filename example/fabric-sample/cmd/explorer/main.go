@@ -1,15 +1,24 @@
+// Command explorer is an explorer of fabric-samples basic chaincode. Its
+// assets are stored as JSON states of `asset` type, which is registered by
+// `value_types` of explorer config:
+//
+//	value_types:
+//	  - chaincode: basic
+//	    key_regexp: ^asset
+//	    state_type: asset
+//	    format: json
+//
+// Protobuf values are registered the same way with `format: protobuf`,
+// `descriptor_set_file` and `message` of value type.
 package main
 
 import (
 	"explorer"
 	"explorer/example/fabric-sample/ui"
-	"explorer/hf"
 	"explorer/pg"
 )
 
 func main() {
-	hf.RegisterJSONValueType("basic", "^asset", "asset")
-
 	pg.RegisterUI(ui.FS)
 
 	pg.RegisterQuery(pg.Query{
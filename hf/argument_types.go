@@ -0,0 +1,83 @@
+package hf
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// argumentKey identifies chaincode function argument by its position.
+// Position is counted from zero after function name.
+type argumentKey struct {
+	chaincodeName string
+	function      string
+	index         int
+}
+
+type argumentType struct {
+	argumentType string
+	format       reflect.Type
+	isJSON       bool
+}
+
+var argumentTypes = map[argumentKey]argumentType{}
+
+func RegisterProtobufArgumentType(chaincodeName string, function string,
+	index int, argType string, format proto.Message) {
+
+	argumentTypes[argumentKey{
+		chaincodeName: chaincodeName,
+		function:      function,
+		index:         index,
+	}] = argumentType{
+		argumentType: argType,
+		format:       reflect.TypeOf(format).Elem(),
+	}
+}
+
+func RegisterJSONArgumentType(chaincodeName string, function string,
+	index int, argType string) {
+
+	argumentTypes[argumentKey{
+		chaincodeName: chaincodeName,
+		function:      function,
+		index:         index,
+	}] = argumentType{
+		argumentType: argType,
+		isJSON:       true,
+	}
+}
+
+// parseArgument parses chaincode function argument using registered argument
+// types. Arguments without registered type are left as raw bytes only.
+func parseArgument(chaincodeName, function string, index int,
+	rawValue []byte) (string, json.RawMessage, error) {
+
+	at, exists := argumentTypes[argumentKey{
+		chaincodeName: chaincodeName,
+		function:      function,
+		index:         index,
+	}]
+	if !exists {
+		return "", nil, nil
+	}
+
+	if at.isJSON {
+		return at.argumentType, rawValue, nil
+	}
+
+	value := reflect.New(at.format).Interface().(proto.Message)
+
+	err := proto.Unmarshal(rawValue, value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	vJSON, err := json.Marshal(value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return at.argumentType, vJSON, nil
+}
@@ -0,0 +1,145 @@
+package hf
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+
+	"explorer"
+)
+
+// decodeBlock returns block with its header hashes, transactions count and
+// size filled.
+func decodeBlock(b *common.Block) *explorer.Block {
+	hash := BlockHeaderHash(b.Header.Number, b.Header.PreviousHash,
+		b.Header.DataHash)
+
+	return &explorer.Block{
+		Number:           int64(b.Header.Number),
+		DataHash:         hex.EncodeToString(b.Header.DataHash),
+		PreviousHash:     hex.EncodeToString(b.Header.PreviousHash),
+		Hash:             hex.EncodeToString(hash),
+		TransactionCount: int64(len(b.GetData().GetData())),
+		Size:             int64(proto.Size(b)),
+	}
+}
+
+// BlockHeaderHash computes block header hash the same way as Fabric does:
+// SHA-256 of ASN.1 DER encoded block number, previous hash and data hash.
+func BlockHeaderHash(number uint64, previousHash, dataHash []byte) []byte {
+	asn1Header := struct {
+		Number       *big.Int
+		PreviousHash []byte
+		DataHash     []byte
+	}{
+		Number:       new(big.Int).SetUint64(number),
+		PreviousHash: previousHash,
+		DataHash:     dataHash,
+	}
+
+	// Marshaling of big.Int and byte slices can not fail.
+	headerBytes, _ := asn1.Marshal(asn1Header)
+
+	hash := sha256.Sum256(headerBytes)
+
+	return hash[:]
+}
+
+// blockMetadata is a decoded block metadata.
+type blockMetadata struct {
+	signers         []*explorer.Identity
+	lastConfigIndex int64
+	commitHash      []byte
+}
+
+// decodeBlockMetadata decodes identities of orderers signed the block, last
+// config block index and commit hash from block metadata. Last config index
+// is taken from orderer block metadata in signatures metadata value, or from
+// deprecated last config metadata for blocks of older Fabric versions.
+func decodeBlockMetadata(b *common.Block) (*blockMetadata, error) {
+
+	bm := &blockMetadata{}
+
+	metadata := b.GetMetadata().GetMetadata()
+
+	var lastConfigFound bool
+
+	if len(metadata) > int(common.BlockMetadataIndex_SIGNATURES) &&
+		len(metadata[common.BlockMetadataIndex_SIGNATURES]) > 0 {
+
+		m := &common.Metadata{}
+		err := proto.Unmarshal(metadata[common.BlockMetadataIndex_SIGNATURES], m)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal signatures metadata: %w", err)
+		}
+
+		for _, ms := range m.Signatures {
+			signatureHeader := &common.SignatureHeader{}
+			err = proto.Unmarshal(ms.SignatureHeader, signatureHeader)
+			if err != nil {
+				return nil, fmt.Errorf("unmarshal signature header: %w", err)
+			}
+			if len(signatureHeader.Creator) == 0 {
+				continue
+			}
+			signer, err := decodeIdentity(signatureHeader.Creator)
+			if err != nil {
+				return nil, fmt.Errorf("decode signer identity: %w", err)
+			}
+			bm.signers = append(bm.signers, signer)
+		}
+
+		if len(m.Value) > 0 {
+			obm := &common.OrdererBlockMetadata{}
+			err = proto.Unmarshal(m.Value, obm)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"unmarshal orderer block metadata: %w", err)
+			}
+			if obm.LastConfig != nil {
+				bm.lastConfigIndex = int64(obm.LastConfig.Index)
+				lastConfigFound = true
+			}
+		}
+	}
+
+	// Deprecated last config metadata is used by blocks of Fabric 1.x.
+	lastConfigIndex := common.BlockMetadataIndex_LAST_CONFIG
+
+	if !lastConfigFound && len(metadata) > int(lastConfigIndex) &&
+		len(metadata[lastConfigIndex]) > 0 {
+
+		m := &common.Metadata{}
+		err := proto.Unmarshal(metadata[lastConfigIndex], m)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal last config metadata: %w", err)
+		}
+
+		lc := &common.LastConfig{}
+		err = proto.Unmarshal(m.Value, lc)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal last config: %w", err)
+		}
+
+		bm.lastConfigIndex = int64(lc.Index)
+	}
+
+	if len(metadata) > int(common.BlockMetadataIndex_COMMIT_HASH) &&
+		len(metadata[common.BlockMetadataIndex_COMMIT_HASH]) > 0 {
+
+		m := &common.Metadata{}
+		err := proto.Unmarshal(metadata[common.BlockMetadataIndex_COMMIT_HASH], m)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal commit hash metadata: %w", err)
+		}
+
+		bm.commitHash = m.Value
+	}
+
+	return bm, nil
+}
@@ -0,0 +1,87 @@
+package hf
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+
+	"explorer"
+)
+
+// BlockEvent is a block delivered by block source with private data of its
+// transactions, if source delivers private data.
+type BlockEvent struct {
+	Block       *common.Block
+	PrivateData map[uint64]*rwset.TxPvtReadWriteSet
+	SourceURL   string
+
+	// rawBlock is a stored raw block which source failed to decode with
+	// decodeErr, block of event has header only.
+	rawBlock  *explorer.RawBlock
+	decodeErr error
+}
+
+// BlockSource delivers blocks of a channel to processor in number order.
+type BlockSource interface {
+	// Start starts delivering of blocks beginning from the given number.
+	Start(fromBlock uint64) error
+
+	// BlockEvents returns channel of delivered blocks. Channel is closed
+	// when source has no more blocks, live sources never close it.
+	BlockEvents() <-chan *BlockEvent
+
+	// Close stops delivering of blocks and releases source resources. It
+	// can be called for not started source.
+	Close()
+}
+
+// memorySource delivers the given blocks, it is useful for testing
+// processor without Fabric network.
+type memorySource struct {
+	blocks []*BlockEvent
+	events chan *BlockEvent
+	wg     sync.WaitGroup
+	close  chan struct{}
+}
+
+// NewMemorySource returns block source delivering the given blocks. Block
+// events channel is closed when all blocks are delivered.
+func NewMemorySource(blocks ...*BlockEvent) BlockSource {
+	return &memorySource{
+		blocks: blocks,
+		events: make(chan *BlockEvent),
+		close:  make(chan struct{}),
+	}
+}
+
+func (s *memorySource) Start(fromBlock uint64) error {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(s.events)
+
+		for _, be := range s.blocks {
+			if be.Block.Header.Number < fromBlock {
+				continue
+			}
+
+			select {
+			case <-s.close:
+				return
+			case s.events <- be:
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *memorySource) BlockEvents() <-chan *BlockEvent {
+	return s.events
+}
+
+func (s *memorySource) Close() {
+	close(s.close)
+	s.wg.Wait()
+}
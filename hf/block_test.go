@@ -0,0 +1,74 @@
+package hf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// genesisBlockFile is a genesis block generated by Fabric configtxgen with
+// SampleSingleMSPSolo profile, copied from testdata of
+// github.com/hyperledger/fabric-config.
+const genesisBlockFile = "testdata/genesis_block.pb"
+
+// Header hashes are SHA-256 of DER encoded headers, which are built byte by
+// byte independently of encoding/asn1.
+const (
+	genesisBlockHash = "6f964a77e2c6adaf7e32ed5f3deb5d6277ef124b9823cb4e436f5a012e05b4b6"
+	block300Hash     = "9c036557f368b1b4e97848c7087d5102e440014b3b023841e3fd06740cdca1b0"
+)
+
+func TestBlockHeaderHashGenesisBlock(t *testing.T) {
+	blockBytes, err := ioutil.ReadFile(genesisBlockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &common.Block{}
+
+	err = proto.Unmarshal(blockBytes, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Data hash is computed by configtxgen, it confirms that block is read
+	// as Fabric wrote it.
+	dataHash := sha256.Sum256(bytes.Join(b.Data.Data, nil))
+	if !bytes.Equal(dataHash[:], b.Header.DataHash) {
+		t.Fatalf("got data hash %x, want %x", b.Header.DataHash, dataHash)
+	}
+
+	got := decodeBlock(b)
+
+	if got.Number != 0 || got.PreviousHash != "" ||
+		got.DataHash != hex.EncodeToString(b.Header.DataHash) {
+		t.Errorf("got block %d with previous hash `%s` and data hash `%s`, "+
+			"want genesis block header", got.Number, got.PreviousHash,
+			got.DataHash)
+	}
+
+	if got.Hash != genesisBlockHash {
+		t.Errorf("got genesis block hash %s, want %s", got.Hash,
+			genesisBlockHash)
+	}
+}
+
+func TestBlockHeaderHashMultiByteNumber(t *testing.T) {
+	previousHash, err := hex.DecodeString(genesisBlockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataHash := sha256.Sum256(nil)
+
+	got := hex.EncodeToString(BlockHeaderHash(300, previousHash,
+		dataHash[:]))
+	if got != block300Hash {
+		t.Errorf("got block 300 hash %s, want %s", got, block300Hash)
+	}
+}
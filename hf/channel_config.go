@@ -0,0 +1,357 @@
+package hf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/orderer"
+	fabricPeer "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/sirupsen/logrus"
+
+	"explorer"
+)
+
+// Well known channel config group, value and organization type names.
+const (
+	channelGroup     = "Channel"
+	applicationGroup = "Application"
+	ordererGroup     = "Orderer"
+
+	mspValue              = "MSP"
+	anchorPeersValue      = "AnchorPeers"
+	endpointsValue        = "Endpoints"
+	ordererAddressesValue = "OrdererAddresses"
+	consensusTypeValue    = "ConsensusType"
+	batchSizeValue        = "BatchSize"
+	batchTimeoutValue     = "BatchTimeout"
+
+	applicationOrganization = "application"
+	ordererOrganization     = "orderer"
+)
+
+// ChannelConfig is a decoded channel config with organizations and
+// policies of its config tree.
+type ChannelConfig struct {
+	Config        *explorer.ChannelConfig
+	Organizations []*explorer.ChannelOrganization
+	Policies      []*explorer.ChannelPolicy
+}
+
+func decodeChannelConfig(log *logrus.Entry,
+	channelHeader *common.ChannelHeader, payload *common.Payload) (
+	*ChannelConfig, error) {
+
+	configEnvelope := &common.ConfigEnvelope{}
+
+	err := proto.Unmarshal(payload.Data, configEnvelope)
+	if err != nil {
+		return nil, fmt.Errorf("parse channel config: %w", err)
+	}
+
+	if configEnvelope.Config == nil ||
+		configEnvelope.Config.ChannelGroup == nil {
+		return nil, fmt.Errorf("channel group not found")
+	}
+
+	cc := &ChannelConfig{
+		Config: &explorer.ChannelConfig{
+			CreatedAt: channelHeader.Timestamp,
+			Raw:       payload.Data,
+			Sequence:  int64(configEnvelope.Config.Sequence),
+		},
+	}
+
+	cc.Config.Parsed, err = json.Marshal(configEnvelope.Config)
+	if err != nil {
+		return nil, fmt.Errorf("JSON marshal config: %w", err)
+	}
+
+	root := configEnvelope.Config.ChannelGroup
+
+	if v, exists := root.Values[ordererAddressesValue]; exists {
+		cc.Config.OrdererAddresses, err = decodeOrdererAddresses(v)
+		if err != nil {
+			return nil, fmt.Errorf("decode orderer addresses: %w", err)
+		}
+	}
+
+	if g, exists := root.Groups[ordererGroup]; exists {
+		err = cc.decodeOrdererValues(g)
+		if err != nil {
+			return nil, fmt.Errorf("decode orderer values: %w", err)
+		}
+	}
+
+	for groupName, orgType := range map[string]string{
+		applicationGroup: applicationOrganization,
+		ordererGroup:     ordererOrganization,
+	} {
+		g, exists := root.Groups[groupName]
+		if !exists {
+			continue
+		}
+		for name, og := range g.Groups {
+			o, err := decodeOrganization(orgType, name, og)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"decode organization `%s`: %w", name, err)
+			}
+			cc.Organizations = append(cc.Organizations, o)
+		}
+	}
+
+	sort.Slice(cc.Organizations, func(i, j int) bool {
+		oi, oj := cc.Organizations[i], cc.Organizations[j]
+		if oi.Type != oj.Type {
+			return oi.Type < oj.Type
+		}
+		return oi.Name < oj.Name
+	})
+
+	cc.decodePolicies(log, channelGroup, root)
+
+	return cc, nil
+}
+
+func (cc *ChannelConfig) decodeOrdererValues(g *common.ConfigGroup) error {
+	if v, exists := g.Values[consensusTypeValue]; exists {
+		ct := &orderer.ConsensusType{}
+		err := proto.Unmarshal(v.Value, ct)
+		if err != nil {
+			return fmt.Errorf("unmarshal consensus type: %w", err)
+		}
+		cc.Config.ConsensusType = ct.Type
+	}
+
+	if v, exists := g.Values[batchSizeValue]; exists {
+		bs := &orderer.BatchSize{}
+		err := proto.Unmarshal(v.Value, bs)
+		if err != nil {
+			return fmt.Errorf("unmarshal batch size: %w", err)
+		}
+		cc.Config.BatchMaxMessageCount = int64(bs.MaxMessageCount)
+		cc.Config.BatchAbsoluteMaxBytes = int64(bs.AbsoluteMaxBytes)
+		cc.Config.BatchPreferredMaxBytes = int64(bs.PreferredMaxBytes)
+	}
+
+	if v, exists := g.Values[batchTimeoutValue]; exists {
+		bt := &orderer.BatchTimeout{}
+		err := proto.Unmarshal(v.Value, bt)
+		if err != nil {
+			return fmt.Errorf("unmarshal batch timeout: %w", err)
+		}
+		cc.Config.BatchTimeout = bt.Timeout
+	}
+
+	return nil
+}
+
+func decodeOrganization(orgType, name string, g *common.ConfigGroup) (
+	*explorer.ChannelOrganization, error) {
+
+	o := &explorer.ChannelOrganization{
+		Type: orgType,
+		Name: name,
+	}
+
+	if v, exists := g.Values[mspValue]; exists {
+		mspConfig := &msp.MSPConfig{}
+		err := proto.Unmarshal(v.Value, mspConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal MSP config: %w", err)
+		}
+
+		// Only X.509 based MSP configs carry certificates, idemix MSP
+		// configs are left with MSP ID being organization name.
+		o.MspId = name
+
+		if mspConfig.Type == 0 {
+			fabricMSPConfig := &msp.FabricMSPConfig{}
+			err = proto.Unmarshal(mspConfig.Config, fabricMSPConfig)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"unmarshal fabric MSP config: %w", err)
+			}
+			o.MspId = fabricMSPConfig.Name
+			o.RootCerts = pemStrings(fabricMSPConfig.RootCerts)
+			o.IntermediateCerts = pemStrings(
+				fabricMSPConfig.IntermediateCerts)
+			o.TlsRootCerts = pemStrings(fabricMSPConfig.TlsRootCerts)
+		}
+	}
+
+	if v, exists := g.Values[anchorPeersValue]; exists {
+		aps := &fabricPeer.AnchorPeers{}
+		err := proto.Unmarshal(v.Value, aps)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal anchor peers: %w", err)
+		}
+		for _, ap := range aps.AnchorPeers {
+			o.AnchorPeers = append(o.AnchorPeers,
+				net.JoinHostPort(ap.Host, strconv.Itoa(int(ap.Port))))
+		}
+	}
+
+	if v, exists := g.Values[endpointsValue]; exists {
+		var err error
+		o.OrdererEndpoints, err = decodeOrdererAddresses(v)
+		if err != nil {
+			return nil, fmt.Errorf("decode orderer endpoints: %w", err)
+		}
+	}
+
+	return o, nil
+}
+
+func decodeOrdererAddresses(v *common.ConfigValue) ([]string, error) {
+	oas := &common.OrdererAddresses{}
+	err := proto.Unmarshal(v.Value, oas)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal orderer addresses: %w", err)
+	}
+	return oas.Addresses, nil
+}
+
+// decodePolicies decodes policies of config group and all its subgroups.
+// Policy path is a slash separated path of group names. Policies with rules
+// failed to decode are logged and stored with empty rule, since channel
+// config is valid for the ordering service which committed it.
+func (cc *ChannelConfig) decodePolicies(log *logrus.Entry, path string,
+	g *common.ConfigGroup) {
+
+	names := make([]string, 0, len(g.Policies))
+	for name := range g.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := g.Policies[name]
+
+		cp := &explorer.ChannelPolicy{
+			Path:      path,
+			Name:      name,
+			ModPolicy: p.ModPolicy,
+		}
+
+		if p.Policy != nil {
+			var err error
+			cp.Type = common.Policy_PolicyType(p.Policy.Type).String()
+			cp.Rule, err = policyRule(p.Policy)
+			if err != nil {
+				log.WithError(err).WithFields(logrus.Fields{
+					"policy_path": path,
+					"policy_name": name,
+				}).Warning("failed to decode policy rule")
+			}
+		}
+
+		cc.Policies = append(cc.Policies, cp)
+	}
+
+	groupNames := make([]string, 0, len(g.Groups))
+	for name := range g.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	for _, name := range groupNames {
+		cc.decodePolicies(log, path+"/"+name, g.Groups[name])
+	}
+}
+
+// policyRule returns human readable policy rule, for example
+// `MAJORITY Admins` or `OutOf(1, 'Org1MSP.admin')`.
+func policyRule(p *common.Policy) (string, error) {
+	switch common.Policy_PolicyType(p.Type) {
+	case common.Policy_IMPLICIT_META:
+		imp := &common.ImplicitMetaPolicy{}
+		err := proto.Unmarshal(p.Value, imp)
+		if err != nil {
+			return "", fmt.Errorf("unmarshal implicit meta policy: %w", err)
+		}
+		return imp.Rule.String() + " " + imp.SubPolicy, nil
+
+	case common.Policy_SIGNATURE:
+		spe := &common.SignaturePolicyEnvelope{}
+		err := proto.Unmarshal(p.Value, spe)
+		if err != nil {
+			return "", fmt.Errorf("unmarshal signature policy: %w", err)
+		}
+		return signaturePolicyRule(spe.Rule, spe.Identities)
+	}
+
+	return "", nil
+}
+
+func signaturePolicyRule(sp *common.SignaturePolicy,
+	identities []*msp.MSPPrincipal) (string, error) {
+
+	switch t := sp.GetType().(type) {
+	case *common.SignaturePolicy_SignedBy:
+		if t.SignedBy < 0 || int(t.SignedBy) >= len(identities) {
+			return "", fmt.Errorf("identity %d not found", t.SignedBy)
+		}
+		return principalString(identities[t.SignedBy])
+
+	case *common.SignaturePolicy_NOutOf_:
+		rules := []string{strconv.Itoa(int(t.NOutOf.GetN()))}
+		for _, r := range t.NOutOf.GetRules() {
+			rule, err := signaturePolicyRule(r, identities)
+			if err != nil {
+				return "", err
+			}
+			rules = append(rules, rule)
+		}
+		return "OutOf(" + strings.Join(rules, ", ") + ")", nil
+	}
+
+	return "", fmt.Errorf("unexpected signature policy type %T",
+		sp.GetType())
+}
+
+func principalString(p *msp.MSPPrincipal) (string, error) {
+	switch p.PrincipalClassification {
+	case msp.MSPPrincipal_ROLE:
+		role := &msp.MSPRole{}
+		err := proto.Unmarshal(p.Principal, role)
+		if err != nil {
+			return "", fmt.Errorf("unmarshal MSP role: %w", err)
+		}
+		return fmt.Sprintf("'%s.%s'", role.MspIdentifier,
+			strings.ToLower(role.Role.String())), nil
+
+	case msp.MSPPrincipal_ORGANIZATION_UNIT:
+		ou := &msp.OrganizationUnit{}
+		err := proto.Unmarshal(p.Principal, ou)
+		if err != nil {
+			return "", fmt.Errorf("unmarshal organization unit: %w", err)
+		}
+		return fmt.Sprintf("'%s.OU(%s)'", ou.MspIdentifier,
+			ou.OrganizationalUnitIdentifier), nil
+
+	case msp.MSPPrincipal_IDENTITY:
+		i, err := decodeIdentity(p.Principal)
+		if err != nil {
+			return "", fmt.Errorf("decode identity: %w", err)
+		}
+		return fmt.Sprintf("'%s.identity(%s)'", i.MspId, i.Subject), nil
+	}
+
+	return "'" + p.PrincipalClassification.String() + "'", nil
+}
+
+func pemStrings(certs [][]byte) []string {
+	ss := make([]string, 0, len(certs))
+	for _, c := range certs {
+		ss = append(ss, string(c))
+	}
+	return ss
+}
@@ -0,0 +1,187 @@
+package hf
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/sirupsen/logrus"
+)
+
+func testRolePrincipal(t *testing.T, mspID string,
+	role msp.MSPRole_MSPRoleType) *msp.MSPPrincipal {
+
+	t.Helper()
+
+	return &msp.MSPPrincipal{
+		PrincipalClassification: msp.MSPPrincipal_ROLE,
+		Principal: mustMarshal(t, &msp.MSPRole{
+			MspIdentifier: mspID,
+			Role:          role,
+		}),
+	}
+}
+
+func signedBy(i int32) *common.SignaturePolicy {
+	return &common.SignaturePolicy{
+		Type: &common.SignaturePolicy_SignedBy{SignedBy: i},
+	}
+}
+
+func outOf(n int32, rules ...*common.SignaturePolicy) *common.SignaturePolicy {
+	return &common.SignaturePolicy{
+		Type: &common.SignaturePolicy_NOutOf_{
+			NOutOf: &common.SignaturePolicy_NOutOf{N: n, Rules: rules},
+		},
+	}
+}
+
+func testSignaturePolicy(t *testing.T, rule *common.SignaturePolicy,
+	identities ...*msp.MSPPrincipal) *common.Policy {
+
+	t.Helper()
+
+	return &common.Policy{
+		Type: int32(common.Policy_SIGNATURE),
+		Value: mustMarshal(t, &common.SignaturePolicyEnvelope{
+			Rule:       rule,
+			Identities: identities,
+		}),
+	}
+}
+
+func testImplicitMetaPolicy(t *testing.T,
+	rule common.ImplicitMetaPolicy_Rule, subPolicy string) *common.Policy {
+
+	t.Helper()
+
+	return &common.Policy{
+		Type: int32(common.Policy_IMPLICIT_META),
+		Value: mustMarshal(t, &common.ImplicitMetaPolicy{
+			Rule:      rule,
+			SubPolicy: subPolicy,
+		}),
+	}
+}
+
+func TestPolicyRule(t *testing.T) {
+	var (
+		org1Admin = testRolePrincipal(t, "Org1MSP", msp.MSPRole_ADMIN)
+		org2Peer  = testRolePrincipal(t, "Org2MSP", msp.MSPRole_PEER)
+		org3OU    = &msp.MSPPrincipal{
+			PrincipalClassification: msp.MSPPrincipal_ORGANIZATION_UNIT,
+			Principal: mustMarshal(t, &msp.OrganizationUnit{
+				MspIdentifier:                "Org3MSP",
+				OrganizationalUnitIdentifier: "client",
+			}),
+		}
+	)
+
+	for _, c := range []struct {
+		name    string
+		policy  *common.Policy
+		rule    string
+		wantErr bool
+	}{{
+		name: "implicit meta",
+		policy: testImplicitMetaPolicy(t, common.ImplicitMetaPolicy_MAJORITY,
+			"Admins"),
+		rule: "MAJORITY Admins",
+	}, {
+		name:   "signed by",
+		policy: testSignaturePolicy(t, signedBy(0), org1Admin),
+		rule:   "'Org1MSP.admin'",
+	}, {
+		name: "nested out of",
+		policy: testSignaturePolicy(t,
+			outOf(1, signedBy(0), outOf(2, signedBy(1), signedBy(2))),
+			org1Admin, org2Peer, org3OU),
+		rule: "OutOf(1, 'Org1MSP.admin', " +
+			"OutOf(2, 'Org2MSP.peer', 'Org3MSP.OU(client)'))",
+	}, {
+		name:   "unknown type",
+		policy: &common.Policy{Type: int32(common.Policy_MSP)},
+	}, {
+		name:    "signed by out of range",
+		policy:  testSignaturePolicy(t, signedBy(1), org1Admin),
+		wantErr: true,
+	}, {
+		name:    "signed by negative",
+		policy:  testSignaturePolicy(t, signedBy(-1), org1Admin),
+		wantErr: true,
+	}, {
+		name: "nested signed by out of range",
+		policy: testSignaturePolicy(t,
+			outOf(1, signedBy(0), outOf(1, signedBy(5))), org1Admin),
+		wantErr: true,
+	}, {
+		name: "malformed signature policy",
+		policy: &common.Policy{
+			Type:  int32(common.Policy_SIGNATURE),
+			Value: []byte{0xff},
+		},
+		wantErr: true,
+	}} {
+		rule, err := policyRule(c.policy)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: got error %v, want error %t", c.name, err,
+				c.wantErr)
+			continue
+		}
+		if rule != c.rule {
+			t.Errorf("%s: got rule `%s`, want `%s`", c.name, rule, c.rule)
+		}
+	}
+}
+
+func TestDecodeChannelConfigBadPolicy(t *testing.T) {
+	org1Admin := testRolePrincipal(t, "Org1MSP", msp.MSPRole_ADMIN)
+
+	payload := &common.Payload{
+		Data: mustMarshal(t, &common.ConfigEnvelope{
+			Config: &common.Config{
+				Sequence: 3,
+				ChannelGroup: &common.ConfigGroup{
+					Policies: map[string]*common.ConfigPolicy{
+						"Admins": {
+							Policy: testImplicitMetaPolicy(t,
+								common.ImplicitMetaPolicy_MAJORITY, "Admins"),
+							ModPolicy: "Admins",
+						},
+						"Writers": {
+							Policy: testSignaturePolicy(t, signedBy(1),
+								org1Admin),
+							ModPolicy: "Admins",
+						},
+					},
+				},
+			},
+		}),
+	}
+
+	cc, err := decodeChannelConfig(logrus.WithField("test", t.Name()),
+		&common.ChannelHeader{}, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cc.Config.Sequence != 3 {
+		t.Errorf("got sequence %d, want 3", cc.Config.Sequence)
+	}
+
+	if len(cc.Policies) != 2 {
+		t.Fatalf("got %d policies, want 2", len(cc.Policies))
+	}
+
+	for i, want := range []struct{ name, policyType, rule string }{
+		{"Admins", "IMPLICIT_META", "MAJORITY Admins"},
+		{"Writers", "SIGNATURE", ""},
+	} {
+		p := cc.Policies[i]
+		if p.Path != "Channel" || p.Name != want.name ||
+			p.Type != want.policyType || p.Rule != want.rule {
+			t.Errorf("got policy %d %v, want Channel/%s %s with rule `%s`",
+				i, p, want.name, want.policyType, want.rule)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package hf
+
+import "explorer"
+
+// DecodedBlock is a block of a channel decoded to entities ready to be
+// stored. IDs of entities and references between them are set by storage.
+// Creators are creator identities of transactions with the same index, nil
+// if creator is not decoded.
+type DecodedBlock struct {
+	ChannelName    string
+	SourceURL      string
+	Block          *explorer.Block
+	RawBlock       *explorer.RawBlock
+	Signers        []*explorer.Identity
+	ChannelConfigs []*ChannelConfig
+	Chaincodes     []*explorer.Chaincode
+	Transactions   []*explorer.Transaction
+	Creators       []*explorer.Identity
+	Arguments      []*explorer.Argument
+	Reads          []*explorer.Read
+	RangeQueries   []RangeQuery
+	States         []StateWrite
+
+	PrivateWriteHashes []*explorer.PrivateWriteHash
+	PrivateWrites      []*explorer.PrivateWrite
+	ChaincodeEvents    []*explorer.ChaincodeEvent
+	Endorsements       []*explorer.Endorsement
+
+	ChaincodeDefinitions []*ChaincodeDefinitionChange
+}
@@ -0,0 +1,349 @@
+package hf
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dimuls/fabric-sdk-go/pkg/client/event"
+	contextAPI "github.com/dimuls/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/dimuls/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/dimuls/fabric-sdk-go/pkg/core/config"
+	"github.com/dimuls/fabric-sdk-go/pkg/fab/comm"
+	"github.com/dimuls/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/dimuls/fabric-sdk-go/pkg/fab/txn"
+	"github.com/dimuls/fabric-sdk-go/pkg/fabsdk"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/orderer"
+	fabricPeer "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/sirupsen/logrus"
+)
+
+// NewFabricSource returns block source delivering blocks from channel peers
+// using fabric SDK configured by the processor config. Blocks with private
+// data are delivered if private data is enabled.
+func NewFabricSource(c ProcessorConfig) (BlockSource, error) {
+
+	fsdk, err := fabsdk.New(config.FromFile(c.FabricConfigFile))
+	if err != nil {
+		return nil, fmt.Errorf("create fabric SDK: %w", err)
+	}
+
+	if !c.PrivateData {
+		return newEventSource(fsdk, c), nil
+	}
+
+	s, err := newPrivateDataSource(fsdk, c)
+	if err != nil {
+		fsdk.Close()
+		return nil, fmt.Errorf("create private data source: %w", err)
+	}
+
+	return s, nil
+}
+
+// eventSource delivers blocks using fabric SDK event client.
+type eventSource struct {
+	fsdk        *fabsdk.FabricSDK
+	channelName string
+	user        string
+	client      *event.Client
+	reg         fab.Registration
+	events      chan *BlockEvent
+	wg          sync.WaitGroup
+	close       chan struct{}
+}
+
+func newEventSource(fsdk *fabsdk.FabricSDK, c ProcessorConfig) *eventSource {
+	return &eventSource{
+		fsdk:        fsdk,
+		channelName: c.ChannelName,
+		user:        c.User,
+		events:      make(chan *BlockEvent),
+		close:       make(chan struct{}),
+	}
+}
+
+func (s *eventSource) Start(fromBlock uint64) error {
+
+	chCtx := s.fsdk.ChannelContext(s.channelName,
+		fabsdk.WithUser(s.user))
+
+	evClient, err := event.New(chCtx, event.WithBlockEvents(),
+		event.WithSeekType(seek.FromBlock), event.WithBlockNum(fromBlock))
+	if err != nil {
+		return fmt.Errorf("create event client: %w", err)
+	}
+
+	reg, bes, err := evClient.RegisterBlockEvent()
+	if err != nil {
+		return fmt.Errorf("register block event: %w", err)
+	}
+
+	s.client = evClient
+	s.reg = reg
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		for {
+			select {
+			case <-s.close:
+				return
+			case be := <-bes:
+				select {
+				case <-s.close:
+					return
+				case s.events <- &BlockEvent{
+					Block:     be.Block,
+					SourceURL: be.SourceURL,
+				}:
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *eventSource) BlockEvents() <-chan *BlockEvent {
+	return s.events
+}
+
+func (s *eventSource) Close() {
+	close(s.close)
+	s.wg.Wait()
+	if s.client != nil {
+		s.client.Unregister(s.reg)
+	}
+	s.fsdk.Close()
+}
+
+// privateDataSource delivers blocks with private data using peer
+// DeliverWithPrivateData service, which is not supported by fabric SDK
+// event client. Peers return private data of collections which configured
+// user organization is member of.
+type privateDataSource struct {
+	fsdk            *fabsdk.FabricSDK
+	ctx             contextAPI.Client
+	channelName     string
+	peers           []fab.ChannelPeer
+	nextBlockNumber uint64
+	events          chan *BlockEvent
+	log             *logrus.Entry
+	wg              sync.WaitGroup
+	close           chan struct{}
+}
+
+func newPrivateDataSource(fsdk *fabsdk.FabricSDK, c ProcessorConfig) (
+	*privateDataSource, error) {
+
+	ctx, err := fsdk.Context(fabsdk.WithUser(c.User),
+		fabsdk.WithOrg(c.Organization))()
+	if err != nil {
+		return nil, fmt.Errorf("create client context: %w", err)
+	}
+
+	mspID := ctx.Identifier().MSPID
+
+	var peers []fab.ChannelPeer
+
+	for _, p := range ctx.EndpointConfig().ChannelPeers(c.ChannelName) {
+		if p.MSPID == mspID && p.EventSource {
+			peers = append(peers, p)
+		}
+	}
+
+	if len(peers) == 0 {
+		return nil, fmt.Errorf(
+			"no event source peers of `%s` found in channel", mspID)
+	}
+
+	return &privateDataSource{
+		fsdk:        fsdk,
+		ctx:         ctx,
+		channelName: c.ChannelName,
+		peers:       peers,
+		events:      make(chan *BlockEvent),
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":  "block_source",
+			"channel_id": c.ChannelName,
+			"source":     "private_data",
+		}),
+		close: make(chan struct{}),
+	}, nil
+}
+
+func (s *privateDataSource) Start(fromBlock uint64) error {
+	s.nextBlockNumber = fromBlock
+
+	s.wg.Add(1)
+	go s.run()
+
+	return nil
+}
+
+func (s *privateDataSource) run() {
+	defer s.wg.Done()
+
+	for {
+		for _, p := range s.peers {
+			err := s.deliver(p.URL, &p.PeerConfig)
+
+			select {
+			case <-s.close:
+				return
+			default:
+			}
+
+			s.log.WithError(err).WithField("peer_url", p.URL).
+				Error("failed to deliver blocks with private data")
+		}
+
+		t := time.NewTimer(10 * time.Second)
+
+		select {
+		case <-s.close:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *privateDataSource) deliver(url string,
+	peerConfig *fab.PeerConfig) error {
+
+	conn, err := comm.NewConnection(s.ctx, url,
+		comm.OptsFromPeerConfig(peerConfig)...)
+	if err != nil {
+		return fmt.Errorf("connect to peer: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-s.close:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stream, err := fabricPeer.NewDeliverClient(conn.ClientConn()).
+		DeliverWithPrivateData(ctx)
+	if err != nil {
+		return fmt.Errorf("open deliver stream: %w", err)
+	}
+
+	env, err := s.seekEnvelope(conn.TLSCertHash())
+	if err != nil {
+		return fmt.Errorf("create seek envelope: %w", err)
+	}
+
+	err = stream.Send(env)
+	if err != nil {
+		return fmt.Errorf("send seek envelope: %w", err)
+	}
+
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("receive deliver response: %w", err)
+		}
+
+		switch r := res.Type.(type) {
+		case *fabricPeer.DeliverResponse_Status:
+			return fmt.Errorf("deliver finished with status `%s`",
+				r.Status)
+		case *fabricPeer.DeliverResponse_BlockAndPrivateData:
+			select {
+			case <-s.close:
+				return nil
+			case s.events <- &BlockEvent{
+				Block:       r.BlockAndPrivateData.Block,
+				PrivateData: r.BlockAndPrivateData.PrivateDataMap,
+				SourceURL:   url,
+			}:
+				s.nextBlockNumber =
+					r.BlockAndPrivateData.Block.Header.Number + 1
+			}
+		default:
+			return fmt.Errorf("unexpected deliver response type %T", r)
+		}
+	}
+}
+
+func (s *privateDataSource) seekEnvelope(tlsCertHash []byte) (
+	*common.Envelope, error) {
+
+	txh, err := txn.NewHeader(s.ctx, s.channelName)
+	if err != nil {
+		return nil, fmt.Errorf("create transaction header: %w", err)
+	}
+
+	channelHeader, err := txn.CreateChannelHeader(
+		common.HeaderType_DELIVER_SEEK_INFO, txn.ChannelHeaderOpts{
+			TxnHeader:   txh,
+			TLSCertHash: tlsCertHash,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("create channel header: %w", err)
+	}
+
+	seekInfo, err := proto.Marshal(&orderer.SeekInfo{
+		Start:    seekPosition(s.nextBlockNumber),
+		Stop:     seekPosition(math.MaxUint64),
+		Behavior: orderer.SeekInfo_BLOCK_UNTIL_READY,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal seek info: %w", err)
+	}
+
+	payload, err := txn.CreatePayload(txh, channelHeader, seekInfo)
+	if err != nil {
+		return nil, fmt.Errorf("create payload: %w", err)
+	}
+
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
+	}
+
+	signature, err := s.ctx.SigningManager().Sign(payloadBytes,
+		s.ctx.PrivateKey())
+	if err != nil {
+		return nil, fmt.Errorf("sign payload: %w", err)
+	}
+
+	return &common.Envelope{
+		Payload:   payloadBytes,
+		Signature: signature,
+	}, nil
+}
+
+func seekPosition(blockNumber uint64) *orderer.SeekPosition {
+	return &orderer.SeekPosition{
+		Type: &orderer.SeekPosition_Specified{
+			Specified: &orderer.SeekSpecified{
+				Number: blockNumber,
+			},
+		},
+	}
+}
+
+func (s *privateDataSource) BlockEvents() <-chan *BlockEvent {
+	return s.events
+}
+
+func (s *privateDataSource) Close() {
+	close(s.close)
+	s.wg.Wait()
+	s.fsdk.Close()
+}
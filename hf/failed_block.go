@@ -0,0 +1,137 @@
+package hf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"explorer"
+)
+
+const (
+	FailedBlockPolicySkip = "skip"
+	FailedBlockPolicyHalt = "halt"
+)
+
+var ErrProcessorStopped = errors.New("processor stopped")
+
+type retryRequest struct {
+	be     *BlockEvent
+	result chan error
+}
+
+// storeFailedBlock stores block which processor failed to process with the
+// last error and moves channel checkpoint past it. Block is also stored as
+// raw block if raw blocks are enabled, so it is retried by reindex. Raw block
+// which source failed to decode is stored as is.
+func (p *Processor) storeFailedBlock(be *BlockEvent, failures int,
+	processErr error) (err error) {
+
+	rb := be.rawBlock
+
+	if rb == nil {
+		rb, err = encodeRawBlock(be, p.compressRawBlocks)
+		if err != nil {
+			return fmt.Errorf("encode raw block: %w", err)
+		}
+	}
+
+	fb := &explorer.FailedBlock{
+		Number:      rb.Number,
+		Compression: rb.Compression,
+		SourceUrl:   rb.SourceUrl,
+		Data:        rb.Data,
+		Error:       processErr.Error(),
+		Failures:    int64(failures),
+	}
+
+	if !p.rawBlocks {
+		rb = nil
+	}
+
+	ctx := context.TODO()
+
+	tx, err := p.storage.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin storage transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err2 := tx.Rollback()
+			if err2 != nil {
+				p.log.WithError(err2).
+					Error("failed to rollback storage transaction")
+			}
+		}
+	}()
+
+	err = tx.WriteFailedBlock(ctx, p.channelName, fb, rb)
+	if err != nil {
+		return fmt.Errorf("write failed block to storage: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit storage transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RetryFailedBlock processes failed block again, for example after decoder
+// fix. Block is processed between blocks of block source and is removed
+// from failed blocks if it is processed successfully.
+func (p *Processor) RetryFailedBlock(ctx context.Context,
+	fb *explorer.FailedBlock) error {
+
+	be, err := decodeRawBlock(&explorer.RawBlock{
+		Number:      fb.Number,
+		Compression: fb.Compression,
+		SourceUrl:   fb.SourceUrl,
+		Data:        fb.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("decode failed block: %w", err)
+	}
+
+	r := &retryRequest{
+		be:     be,
+		result: make(chan error, 1),
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.done:
+		return ErrProcessorStopped
+	case p.retries <- r:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err = <-r.result:
+		return err
+	}
+}
+
+func (p *Processor) retryBlockEvent(be *BlockEvent) error {
+
+	log := p.log.WithFields(logrus.Fields{
+		"block_number": be.Block.Header.Number,
+		"peer_url":     be.SourceURL,
+	})
+
+	err := p.processBlockEvent(log, be, true)
+	if err != nil {
+		log.WithError(err).Error("failed to retry failed block")
+		return err
+	}
+
+	log.Info("failed block processed")
+
+	return nil
+}
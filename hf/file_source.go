@@ -0,0 +1,293 @@
+package hf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/sirupsen/logrus"
+
+	"explorer"
+)
+
+const blockFilePattern = "blockfile_[0-9][0-9][0-9][0-9][0-9][0-9]"
+
+// fileSource delivers blocks read from Fabric ledger block files, for
+// example copied from `chains/<channel>` directory of a peer. Block files
+// contain serialized blocks each prefixed by its varint encoded length.
+// Block events channel is closed when all blocks of all files are delivered.
+//
+// Block failed to deserialize is delivered with decoding error, so failed
+// block policy is applied to it. Block failed to read is delivered the same
+// way, but delivery stops after it, since following blocks can't be found.
+type fileSource struct {
+	files     []string
+	fromBlock uint64
+	nextBlock uint64
+	events    chan *BlockEvent
+	log       *logrus.Entry
+	wg        sync.WaitGroup
+	close     chan struct{}
+}
+
+// NewFileSource returns block source delivering blocks from Fabric ledger
+// block files of the given directory.
+func NewFileSource(dir string) (BlockSource, error) {
+
+	files, err := blockFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no block files found in `%s`", dir)
+	}
+
+	return &fileSource{
+		files:  files,
+		events: make(chan *BlockEvent),
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem": "block_source",
+			"source":    "file",
+		}),
+		close: make(chan struct{}),
+	}, nil
+}
+
+func (s *fileSource) Start(fromBlock uint64) error {
+	s.fromBlock = fromBlock
+
+	s.wg.Add(1)
+	go s.run()
+
+	return nil
+}
+
+// blockFiles returns block files of the directory in ledger order.
+func blockFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, blockFilePattern))
+	if err != nil {
+		return nil, fmt.Errorf("find block files: %w", err)
+	}
+
+	// Block file suffixes are zero padded, so lexical order is ledger order.
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func (s *fileSource) run() {
+	defer s.wg.Done()
+	defer close(s.events)
+
+	for i, f := range s.files {
+		log := s.log.WithField("block_file", f)
+
+		log.Info("reading block file")
+
+		err := s.readBlockFile(f, i == len(s.files)-1)
+		if err != nil {
+			if errors.Is(err, errSourceClosed) {
+				return
+			}
+
+			log.WithError(err).WithField("block_number", s.nextBlock).
+				Error("failed to read block file")
+
+			s.deliver(&BlockEvent{
+				Block: &common.Block{
+					Header: &common.BlockHeader{Number: s.nextBlock},
+				},
+				SourceURL: "file://" + f,
+				decodeErr: fmt.Errorf("read block file: %w", err),
+			})
+			return
+		}
+	}
+}
+
+var errSourceClosed = errors.New("source closed")
+
+// readBlockFile delivers blocks of block file. Partially written block at
+// the end of the last file is the end of the ledger of running peer.
+func (s *fileSource) readBlockFile(path string, last bool) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open block file: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	sourceURL := "file://" + path
+
+	for {
+		length, err := binary.ReadUvarint(r)
+		if err == nil {
+			blockBytes := make([]byte, length)
+			_, err = io.ReadFull(r, blockBytes)
+			if err == nil {
+				err = s.deliver(s.blockEvent(blockBytes, sourceURL))
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		switch {
+		case err == io.EOF:
+			return nil
+		case err == io.ErrUnexpectedEOF && last:
+			s.log.WithField("block_file", path).
+				Warning("partially written block found at file end")
+			return nil
+		}
+
+		return fmt.Errorf("read block: %w", err)
+	}
+}
+
+// blockEvent returns event of serialized block. Block failed to deserialize
+// is passed with decoding error and its bytes as raw block, as storage
+// source passes raw blocks it fails to decode, so the bytes are kept with
+// failed block. Its number is read from the bytes if possible, since number
+// is the first field of serialized block.
+func (s *fileSource) blockEvent(blockBytes []byte,
+	sourceURL string) *BlockEvent {
+
+	b, err := deserializeBlock(blockBytes)
+	if err == nil {
+		s.nextBlock = b.Header.Number + 1
+		return &BlockEvent{Block: b, SourceURL: sourceURL}
+	}
+
+	number, numberErr := proto.NewBuffer(blockBytes).DecodeVarint()
+	if numberErr != nil {
+		number = s.nextBlock
+	}
+
+	s.nextBlock = number + 1
+
+	s.log.WithError(err).WithField("block_number", number).
+		Error("failed to deserialize block")
+
+	return &BlockEvent{
+		Block: &common.Block{
+			Header: &common.BlockHeader{Number: number},
+		},
+		SourceURL: sourceURL,
+		rawBlock: &explorer.RawBlock{
+			Number:      int64(number),
+			Compression: rawBlockCompressionNone,
+			SourceUrl:   sourceURL,
+			Data:        blockBytes,
+		},
+		decodeErr: fmt.Errorf("deserialize block: %w", err),
+	}
+}
+
+// deliver sends block event to processor, blocks before the start block are
+// skipped.
+func (s *fileSource) deliver(be *BlockEvent) error {
+
+	if be.Block.Header.Number < s.fromBlock {
+		return nil
+	}
+
+	select {
+	case <-s.close:
+		return errSourceClosed
+	case s.events <- be:
+		return nil
+	}
+}
+
+func (s *fileSource) BlockEvents() <-chan *BlockEvent {
+	return s.events
+}
+
+func (s *fileSource) Close() {
+	close(s.close)
+	s.wg.Wait()
+}
+
+// deserializeBlock decodes block serialized by Fabric block storage: block
+// number varint, data hash and previous hash raw bytes, count varint and raw
+// bytes of transaction envelopes, count varint and raw bytes of metadata.
+// Raw bytes are prefixed by varint encoded length.
+func deserializeBlock(blockBytes []byte) (*common.Block, error) {
+
+	var (
+		b   = proto.NewBuffer(blockBytes)
+		err error
+	)
+
+	header := &common.BlockHeader{}
+
+	header.Number, err = b.DecodeVarint()
+	if err != nil {
+		return nil, fmt.Errorf("decode block number: %w", err)
+	}
+
+	header.DataHash, err = b.DecodeRawBytes(false)
+	if err != nil {
+		return nil, fmt.Errorf("decode data hash: %w", err)
+	}
+
+	header.PreviousHash, err = b.DecodeRawBytes(false)
+	if err != nil {
+		return nil, fmt.Errorf("decode previous hash: %w", err)
+	}
+
+	if len(header.PreviousHash) == 0 {
+		header.PreviousHash = nil
+	}
+
+	data, err := decodeRawBytesList(b)
+	if err != nil {
+		return nil, fmt.Errorf("decode transaction envelopes: %w", err)
+	}
+
+	metadata, err := decodeRawBytesList(b)
+	if err != nil {
+		return nil, fmt.Errorf("decode metadata: %w", err)
+	}
+
+	return &common.Block{
+		Header:   header,
+		Data:     &common.BlockData{Data: data},
+		Metadata: &common.BlockMetadata{Metadata: metadata},
+	}, nil
+}
+
+// decodeRawBytesList decodes items count varint followed by raw bytes of
+// items.
+func decodeRawBytesList(b *proto.Buffer) ([][]byte, error) {
+
+	n, err := b.DecodeVarint()
+	if err != nil {
+		return nil, fmt.Errorf("decode count: %w", err)
+	}
+
+	var items [][]byte
+
+	for i := uint64(0); i < n; i++ {
+		item, err := b.DecodeRawBytes(false)
+		if err != nil {
+			return nil, fmt.Errorf("decode item %d: %w", i, err)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,388 @@
+package hf
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+var updateFixtures = flag.Bool("update", false, "update test fixtures")
+
+// blockFileFixture is a ledger block file with blocks of fixtureBlocks in
+// Fabric block storage format, it is written by test with -update flag.
+var blockFileFixture = filepath.Join("testdata", "blockfile_000000")
+
+func fixtureEnvelope(t *testing.T, txID string) []byte {
+	t.Helper()
+
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: "mychannel",
+		TxId:      txID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := proto.Marshal(&common.Payload{
+		Header: &common.Header{ChannelHeader: channelHeader},
+		Data:   []byte("transaction " + txID),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope, err := proto.Marshal(&common.Envelope{
+		Payload:   payload,
+		Signature: []byte("signature " + txID),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return envelope
+}
+
+func fixtureBlocks(t *testing.T) []*common.Block {
+	t.Helper()
+
+	metadata := func(n byte) *common.BlockMetadata {
+		return &common.BlockMetadata{Metadata: [][]byte{
+			{n, 1}, {}, {0, 0}, {}, {n, 4},
+		}}
+	}
+
+	return []*common.Block{{
+		Header: &common.BlockHeader{
+			Number:   0,
+			DataHash: []byte("data hash 0"),
+		},
+		Data: &common.BlockData{Data: [][]byte{
+			fixtureEnvelope(t, "tx0"),
+		}},
+		Metadata: metadata(0),
+	}, {
+		Header: &common.BlockHeader{
+			Number:       1,
+			DataHash:     []byte("data hash 1"),
+			PreviousHash: []byte("hash 0"),
+		},
+		Data: &common.BlockData{Data: [][]byte{
+			fixtureEnvelope(t, "tx1"),
+			fixtureEnvelope(t, "tx2"),
+		}},
+		Metadata: metadata(1),
+	}, {
+		Header: &common.BlockHeader{
+			Number:       300,
+			DataHash:     []byte("data hash 300"),
+			PreviousHash: []byte("hash 299"),
+		},
+		Data: &common.BlockData{Data: [][]byte{
+			fixtureEnvelope(t, "tx3"),
+		}},
+		Metadata: metadata(2),
+	}}
+}
+
+// serializeBlock serializes block as Fabric block storage does.
+func serializeBlock(t *testing.T, b *common.Block) []byte {
+	t.Helper()
+
+	buf := proto.NewBuffer(nil)
+
+	for _, err := range []error{
+		buf.EncodeVarint(b.Header.Number),
+		buf.EncodeRawBytes(b.Header.DataHash),
+		buf.EncodeRawBytes(b.Header.PreviousHash),
+		buf.EncodeVarint(uint64(len(b.Data.Data))),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, d := range b.Data.Data {
+		if err := buf.EncodeRawBytes(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := buf.EncodeVarint(uint64(len(b.Metadata.Metadata)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range b.Metadata.Metadata {
+		if err := buf.EncodeRawBytes(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func writeBlockFileFixture(t *testing.T, blocks []*common.Block) {
+	t.Helper()
+
+	var serializedBlocks [][]byte
+
+	for _, b := range blocks {
+		serializedBlocks = append(serializedBlocks, serializeBlock(t, b))
+	}
+
+	writeBlockFile(t, filepath.Dir(blockFileFixture),
+		filepath.Base(blockFileFixture), serializedBlocks...)
+}
+
+func readFileSource(t *testing.T, dir string, fromBlock uint64) (
+	[]*common.Block, []string) {
+
+	t.Helper()
+
+	var (
+		blocks     []*common.Block
+		sourceURLs []string
+	)
+
+	for _, be := range readFileSourceEvents(t, dir, fromBlock) {
+		if be.decodeErr != nil {
+			t.Fatalf("got block %d with error: %v", be.Block.Header.Number,
+				be.decodeErr)
+		}
+		blocks = append(blocks, be.Block)
+		sourceURLs = append(sourceURLs, be.SourceURL)
+	}
+
+	return blocks, sourceURLs
+}
+
+func readFileSourceEvents(t *testing.T, dir string,
+	fromBlock uint64) []*BlockEvent {
+
+	t.Helper()
+
+	bs, err := NewFileSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bs.Close()
+
+	err = bs.Start(fromBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*BlockEvent
+
+	for be := range bs.BlockEvents() {
+		events = append(events, be)
+	}
+
+	return events
+}
+
+// writeBlockFile writes block file of serialized blocks to dir.
+func writeBlockFile(t *testing.T, dir, name string,
+	serializedBlocks ...[]byte) {
+
+	t.Helper()
+
+	buf := proto.NewBuffer(nil)
+
+	for _, blockBytes := range serializedBlocks {
+		if err := buf.EncodeRawBytes(blockBytes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkBlocks(t *testing.T, got, want []*common.Block) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d blocks, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if !proto.Equal(got[i].Header, want[i].Header) {
+			t.Errorf("block %d: got header %v, want %v", i, got[i].Header,
+				want[i].Header)
+		}
+		if !proto.Equal(got[i].Metadata, want[i].Metadata) {
+			t.Errorf("block %d: got metadata %v, want %v", i,
+				got[i].Metadata, want[i].Metadata)
+		}
+		if len(got[i].Data.Data) != len(want[i].Data.Data) {
+			t.Fatalf("block %d: got %d envelopes, want %d", i,
+				len(got[i].Data.Data), len(want[i].Data.Data))
+		}
+		// Envelopes are compared unmarshaled, since fixture is not
+		// rewritten if marshaling of the same envelope changes.
+		for j := range want[i].Data.Data {
+			var g, w common.Envelope
+			if err := proto.Unmarshal(got[i].Data.Data[j], &g); err != nil {
+				t.Fatalf("block %d envelope %d: %v", i, j, err)
+			}
+			if err := proto.Unmarshal(want[i].Data.Data[j], &w); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(&g, &w) {
+				t.Errorf("block %d envelope %d: got %v, want %v", i, j,
+					&g, &w)
+			}
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	blocks := fixtureBlocks(t)
+
+	if *updateFixtures {
+		writeBlockFileFixture(t, blocks)
+	}
+
+	got, sourceURLs := readFileSource(t, "testdata", 0)
+
+	checkBlocks(t, got, blocks)
+
+	for _, u := range sourceURLs {
+		if u != "file://"+blockFileFixture {
+			t.Errorf("got source URL `%s`", u)
+		}
+	}
+
+	got, _ = readFileSource(t, "testdata", 1)
+
+	checkBlocks(t, got, blocks[1:])
+}
+
+func TestFileSourcePartiallyWrittenBlock(t *testing.T) {
+	fixture, err := ioutil.ReadFile(blockFileFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	// The last block is cut, like block being written by running peer.
+	err = ioutil.WriteFile(filepath.Join(dir, "blockfile_000000"),
+		fixture[:len(fixture)-10], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := readFileSource(t, dir, 0)
+
+	checkBlocks(t, got, fixtureBlocks(t)[:2])
+}
+
+func TestDeserializeBlockTruncated(t *testing.T) {
+	blockBytes := serializeBlock(t, fixtureBlocks(t)[1])
+
+	for _, n := range []int{0, 1, 5, len(blockBytes) - 1} {
+		_, err := deserializeBlock(blockBytes[:n])
+		if err == nil {
+			t.Errorf("no error for block truncated to %d bytes", n)
+		}
+	}
+}
+
+func TestFileSourceMalformedBlock(t *testing.T) {
+	var (
+		blocks    = fixtureBlocks(t)
+		dir       = t.TempDir()
+		malformed = serializeBlock(t, blocks[1])
+	)
+
+	// Block length is intact, but its metadata is cut.
+	malformed = malformed[:len(malformed)-3]
+
+	writeBlockFile(t, dir, "blockfile_000000", serializeBlock(t, blocks[0]),
+		malformed, serializeBlock(t, blocks[2]))
+
+	events := readFileSourceEvents(t, dir, 0)
+
+	if len(events) != 3 {
+		t.Fatalf("got %d block events, want 3", len(events))
+	}
+
+	// Block failed to deserialize is passed to processor with error, so
+	// failed block policy is applied to it, and blocks after it are read.
+	be := events[1]
+
+	if be.decodeErr == nil || be.Block.Header.Number != 1 ||
+		be.rawBlock == nil || !bytes.Equal(be.rawBlock.Data, malformed) {
+		t.Errorf("got block event %v with error %v, want block 1 with "+
+			"error and its bytes", be.Block.Header, be.decodeErr)
+	}
+
+	for _, i := range []int{0, 2} {
+		if events[i].decodeErr != nil {
+			t.Fatalf("got block %d error: %v", i, events[i].decodeErr)
+		}
+	}
+
+	checkBlocks(t, []*common.Block{events[0].Block, events[2].Block},
+		[]*common.Block{blocks[0], blocks[2]})
+}
+
+func TestFileSourceCutBlockFile(t *testing.T) {
+	var (
+		blocks = fixtureBlocks(t)
+		dir    = t.TempDir()
+		block1 = serializeBlock(t, blocks[1])
+	)
+
+	writeBlockFile(t, dir, "blockfile_000000", serializeBlock(t, blocks[0]),
+		block1)
+
+	// The first file is cut in block 1 and it is not the last file, so it
+	// is broken, not being written.
+	path := filepath.Join(dir, "blockfile_000000")
+
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(path, fileBytes[:len(fileBytes)-10], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeBlockFile(t, dir, "blockfile_000001", serializeBlock(t, blocks[2]))
+
+	events := readFileSourceEvents(t, dir, 0)
+
+	if len(events) != 2 {
+		t.Fatalf("got %d block events, want block 0 and failed block 1",
+			len(events))
+	}
+
+	checkBlocks(t, []*common.Block{events[0].Block}, blocks[:1])
+
+	be := events[1]
+
+	if be.decodeErr == nil || be.Block.Header.Number != 1 {
+		t.Errorf("got block event %v with error %v, want failed block 1",
+			be.Block.Header, be.decodeErr)
+	}
+
+	// Failed block before start block is not delivered.
+	events = readFileSourceEvents(t, dir, 2)
+
+	if len(events) != 0 {
+		t.Errorf("got %d block events, want none", len(events))
+	}
+}
@@ -0,0 +1,72 @@
+package hf
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"explorer"
+)
+
+// decodeCreator decodes identity of transaction creator from payload header.
+// Returns nil identity if header has no creator, e.g. in genesis block.
+func decodeCreator(h *common.Header) (*explorer.Identity, error) {
+
+	signatureHeader := &common.SignatureHeader{}
+
+	err := proto.Unmarshal(h.GetSignatureHeader(), signatureHeader)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal signature header: %w", err)
+	}
+
+	if len(signatureHeader.Creator) == 0 {
+		return nil, nil
+	}
+
+	return decodeIdentity(signatureHeader.Creator)
+}
+
+// decodeIdentity decodes serialized MSP identity. Non X.509 identities, such
+// as idemix ones, are decoded to MSP ID and fingerprint of identity bytes.
+func decodeIdentity(serializedIdentity []byte) (*explorer.Identity, error) {
+
+	si := &msp.SerializedIdentity{}
+
+	err := proto.Unmarshal(serializedIdentity, si)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal serialized identity: %w", err)
+	}
+
+	i := &explorer.Identity{
+		MspId: si.Mspid,
+	}
+
+	block, _ := pem.Decode(si.IdBytes)
+	if block == nil {
+		fingerprint := sha256.Sum256(si.IdBytes)
+		i.Fingerprint = hex.EncodeToString(fingerprint[:])
+		return i, nil
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	i.Fingerprint = hex.EncodeToString(fingerprint[:])
+	i.Subject = cert.Subject.String()
+	i.Issuer = cert.Issuer.String()
+	i.SerialNumber = cert.SerialNumber.String()
+	i.NotAfter = timestamppb.New(cert.NotAfter)
+
+	return i, nil
+}
@@ -0,0 +1,179 @@
+package hf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	fabricPeer "github.com/hyperledger/fabric-protos-go/peer"
+	fabricLifecycle "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"explorer"
+)
+
+const lifecycle = "_lifecycle"
+
+const (
+	approveChaincodeDefinition = "ApproveChaincodeDefinitionForMyOrg"
+	commitChaincodeDefinition  = "CommitChaincodeDefinition"
+)
+
+// ChaincodeDefinitionChange is a chaincode definition approved by an
+// organization or committed to a channel by _lifecycle transaction.
+// Approval is nil for committed definitions.
+type ChaincodeDefinitionChange struct {
+	Definition *explorer.ChaincodeDefinition
+	Approval   *explorer.ChaincodeApproval
+}
+
+// decodeLifecycle decodes _lifecycle transaction invocation into chaincode
+// definition change. Transactions of other _lifecycle functions are skipped
+// with nil change. Decoded arguments are stored as argument values.
+func decodeLifecycle(transaction *explorer.Transaction,
+	creator *explorer.Identity, function string,
+	args []*explorer.Argument) (*ChaincodeDefinitionChange, error) {
+
+	if function != approveChaincodeDefinition &&
+		function != commitChaincodeDefinition {
+		return nil, nil
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("`%s` arguments not found", function)
+	}
+
+	var (
+		definitionArgs proto.Message
+		argType        string
+	)
+
+	switch function {
+	case approveChaincodeDefinition:
+		definitionArgs = &fabricLifecycle.ApproveChaincodeDefinitionForMyOrgArgs{}
+		argType = "approve_chaincode_definition_args"
+	case commitChaincodeDefinition:
+		definitionArgs = &fabricLifecycle.CommitChaincodeDefinitionArgs{}
+		argType = "commit_chaincode_definition_args"
+	}
+
+	err := proto.Unmarshal(args[0].RawValue, definitionArgs)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal `%s` arguments: %w", function, err)
+	}
+
+	args[0].Type = argType
+	args[0].Value, err = marshalProtoJSON(definitionArgs)
+	if err != nil {
+		return nil, fmt.Errorf("JSON marshal `%s` arguments: %w",
+			function, err)
+	}
+
+	// Approve and commit arguments have the same definition fields.
+	da := definitionArgs.(chaincodeDefinitionArgs)
+
+	cd := &explorer.ChaincodeDefinition{
+		Name:              da.GetName(),
+		Sequence:          da.GetSequence(),
+		Version:           da.GetVersion(),
+		EndorsementPlugin: da.GetEndorsementPlugin(),
+		ValidationPlugin:  da.GetValidationPlugin(),
+		InitRequired:      da.GetInitRequired(),
+	}
+
+	cd.ParametersHash, err = definitionParametersHash(da)
+	if err != nil {
+		return nil, fmt.Errorf("hash definition parameters: %w", err)
+	}
+
+	if len(da.GetValidationParameter()) > 0 {
+		cd.EndorsementPolicy, err = decodeApplicationPolicy(
+			da.GetValidationParameter())
+		if err != nil {
+			return nil, fmt.Errorf("decode endorsement policy: %w", err)
+		}
+	}
+
+	if da.GetCollections() != nil {
+		cd.Collections, err = marshalProtoJSON(da.GetCollections())
+		if err != nil {
+			return nil, fmt.Errorf("JSON marshal collections: %w", err)
+		}
+	}
+
+	if function == commitChaincodeDefinition {
+		cd.TransactionId = transaction.Id
+		cd.CommittedAt = transaction.CreatedAt
+		return &ChaincodeDefinitionChange{Definition: cd}, nil
+	}
+
+	if creator == nil {
+		return nil, fmt.Errorf("approving organization not found")
+	}
+
+	return &ChaincodeDefinitionChange{
+		Definition: cd,
+		Approval: &explorer.ChaincodeApproval{
+			TransactionId: transaction.Id,
+			MspId:         creator.MspId,
+			CreatedAt:     transaction.CreatedAt,
+		},
+	}, nil
+}
+
+func decodeApplicationPolicy(rawPolicy []byte) ([]byte, error) {
+	policy := &fabricPeer.ApplicationPolicy{}
+
+	err := proto.Unmarshal(rawPolicy, policy)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal application policy: %w", err)
+	}
+
+	return marshalProtoJSON(policy)
+}
+
+func marshalProtoJSON(m proto.Message) ([]byte, error) {
+	return protojson.MarshalOptions{UseProtoNames: true}.
+		Marshal(proto.MessageV2(m))
+}
+
+// chaincodeDefinitionArgs are definition fields of approve and commit
+// arguments.
+type chaincodeDefinitionArgs interface {
+	GetSequence() int64
+	GetName() string
+	GetVersion() string
+	GetEndorsementPlugin() string
+	GetValidationPlugin() string
+	GetValidationParameter() []byte
+	GetCollections() *fabricPeer.CollectionConfigPackage
+	GetInitRequired() bool
+}
+
+// definitionParametersHash returns hex encoded SHA-256 hash of definition
+// parameters, which organizations approve and channel commits. Approvals
+// count for committed definition only if their parameters hashes match.
+func definitionParametersHash(da chaincodeDefinitionArgs) (string, error) {
+
+	b := proto.NewBuffer(nil)
+	b.SetDeterministic(true)
+
+	err := b.Marshal(&fabricLifecycle.CommitChaincodeDefinitionArgs{
+		Sequence:            da.GetSequence(),
+		Name:                da.GetName(),
+		Version:             da.GetVersion(),
+		EndorsementPlugin:   da.GetEndorsementPlugin(),
+		ValidationPlugin:    da.GetValidationPlugin(),
+		ValidationParameter: da.GetValidationParameter(),
+		Collections:         da.GetCollections(),
+		InitRequired:        da.GetInitRequired(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(b.Bytes())
+
+	return hex.EncodeToString(hash[:]), nil
+}
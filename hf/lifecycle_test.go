@@ -0,0 +1,152 @@
+package hf
+
+import (
+	"testing"
+
+	fabricPeer "github.com/hyperledger/fabric-protos-go/peer"
+	fabricLifecycle "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+
+	"explorer"
+)
+
+type approveArgs = fabricLifecycle.ApproveChaincodeDefinitionForMyOrgArgs
+
+func testPolicyReference(t *testing.T, reference string) []byte {
+	t.Helper()
+
+	return mustMarshal(t, &fabricPeer.ApplicationPolicy{
+		Type: &fabricPeer.ApplicationPolicy_ChannelConfigPolicyReference{
+			ChannelConfigPolicyReference: reference,
+		},
+	})
+}
+
+func testApproveArgs(t *testing.T) *approveArgs {
+	t.Helper()
+
+	return &approveArgs{
+		Sequence:          1,
+		Name:              "basic",
+		Version:           "1.0",
+		EndorsementPlugin: "escc",
+		ValidationPlugin:  "vscc",
+		ValidationParameter: testPolicyReference(t,
+			"/Channel/Application/Endorsement"),
+		Collections: &fabricPeer.CollectionConfigPackage{
+			Config: []*fabricPeer.CollectionConfig{{
+				Payload: &fabricPeer.CollectionConfig_StaticCollectionConfig{
+					StaticCollectionConfig: &fabricPeer.StaticCollectionConfig{
+						Name:              "assetCollection",
+						RequiredPeerCount: 1,
+						MaximumPeerCount:  3,
+					},
+				},
+			}},
+		},
+		InitRequired: true,
+	}
+}
+
+// approve decodes approval of args by organization of mspID.
+func approve(t *testing.T, mspID string,
+	args *approveArgs) *ChaincodeDefinitionChange {
+
+	t.Helper()
+
+	c, err := decodeLifecycle(&explorer.Transaction{Id: "tx" + mspID},
+		&explorer.Identity{MspId: mspID}, approveChaincodeDefinition,
+		[]*explorer.Argument{{RawValue: mustMarshal(t, args)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestDefinitionParametersHashDeterministic(t *testing.T) {
+	want, err := definitionParametersHash(testApproveArgs(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		got, err := definitionParametersHash(testApproveArgs(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("got hash %s, want %s", got, want)
+		}
+	}
+
+	// Committed definition has the same hash as approved one, so approvals
+	// are matched with it.
+	aa := testApproveArgs(t)
+
+	got, err := definitionParametersHash(
+		&fabricLifecycle.CommitChaincodeDefinitionArgs{
+			Sequence:            aa.Sequence,
+			Name:                aa.Name,
+			Version:             aa.Version,
+			EndorsementPlugin:   aa.EndorsementPlugin,
+			ValidationPlugin:    aa.ValidationPlugin,
+			ValidationParameter: aa.ValidationParameter,
+			Collections:         aa.Collections,
+			InitRequired:        aa.InitRequired,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got commit hash %s, want approval hash %s", got, want)
+	}
+}
+
+func TestDecodeLifecycleApprovalParameters(t *testing.T) {
+	org1 := approve(t, "Org1MSP", testApproveArgs(t))
+	org2 := approve(t, "Org2MSP", testApproveArgs(t))
+
+	if org1.Approval.MspId != "Org1MSP" || org1.Definition.Name != "basic" ||
+		org1.Definition.Sequence != 1 {
+		t.Errorf("got approval %v of definition %v, want Org1MSP approval "+
+			"of basic sequence 1", org1.Approval, org1.Definition)
+	}
+
+	if org1.Definition.ParametersHash != org2.Definition.ParametersHash {
+		t.Errorf("got different hashes %s and %s of the same parameters",
+			org1.Definition.ParametersHash, org2.Definition.ParametersHash)
+	}
+
+	for name, change := range map[string]func(a *approveArgs){
+		"version": func(a *approveArgs) {
+			a.Version = "1.1"
+		},
+		"endorsement_plugin": func(a *approveArgs) {
+			a.EndorsementPlugin = "custom"
+		},
+		"validation_parameter": func(a *approveArgs) {
+			a.ValidationParameter = testPolicyReference(t,
+				"/Channel/Application/Admins")
+		},
+		"collections": func(a *approveArgs) {
+			a.Collections = nil
+		},
+		"init_required": func(a *approveArgs) {
+			a.InitRequired = false
+		},
+	} {
+		args := testApproveArgs(t)
+		change(args)
+
+		c := approve(t, "Org2MSP", args)
+
+		if c.Definition.Sequence != org1.Definition.Sequence {
+			t.Fatalf("%s: got sequence %d, want %d", name,
+				c.Definition.Sequence, org1.Definition.Sequence)
+		}
+		if c.Definition.ParametersHash == org1.Definition.ParametersHash {
+			t.Errorf("%s: got the same hash %s of different parameters",
+				name, c.Definition.ParametersHash)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package hf
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"explorer"
+)
+
+var errStorageTxDone = errors.New(
+	"storage transaction is already committed or rolled back")
+
+// MemoryStorage is a storage keeping channels data in memory, it is useful
+// for testing processor without database.
+type MemoryStorage struct {
+	mu       sync.Mutex
+	channels map[string]*memoryChannel
+	lastID   int64
+}
+
+type memoryStateKey struct {
+	chaincode string
+	key       string
+}
+
+type memoryChannel struct {
+	id              int64
+	lastBlockNumber int64
+	hasCheckpoint   bool
+	blocks          map[int64]*DecodedBlock
+	rawBlocks       map[int64]*explorer.RawBlock
+	failedBlocks    map[int64]*explorer.FailedBlock
+	states          map[memoryStateKey]*explorer.State
+	oldStates       map[memoryStateKey][]*explorer.OldState
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		channels: map[string]*memoryChannel{},
+	}
+}
+
+func (s *MemoryStorage) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+// channel returns data of the channel creating it if it not exists yet. It
+// must be called with mutex locked.
+func (s *MemoryStorage) channel(name string) *memoryChannel {
+	c, ok := s.channels[name]
+	if !ok {
+		c = &memoryChannel{
+			id:        s.nextID(),
+			rawBlocks: map[int64]*explorer.RawBlock{},
+		}
+		c.reset()
+		s.channels[name] = c
+	}
+	return c
+}
+
+// reset removes indexed data of the channel except raw blocks.
+func (c *memoryChannel) reset() {
+	c.lastBlockNumber = 0
+	c.hasCheckpoint = false
+	c.blocks = map[int64]*DecodedBlock{}
+	c.failedBlocks = map[int64]*explorer.FailedBlock{}
+	c.states = map[memoryStateKey]*explorer.State{}
+	c.oldStates = map[memoryStateKey][]*explorer.OldState{}
+}
+
+func (c *memoryChannel) setLastBlockNumber(number int64) {
+	if !c.hasCheckpoint || number > c.lastBlockNumber {
+		c.lastBlockNumber = number
+		c.hasCheckpoint = true
+	}
+}
+
+func (s *MemoryStorage) LastBlockNumber(ctx context.Context,
+	channelName string) (number int64, found bool, err error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[channelName]
+	if !ok || !c.hasCheckpoint {
+		return 0, false, nil
+	}
+
+	return c.lastBlockNumber, true, nil
+}
+
+func (s *MemoryStorage) RawBlocks(ctx context.Context, channelName string,
+	fromNumber int64, limit int) ([]*explorer.RawBlock, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[channelName]
+	if !ok {
+		return nil, nil
+	}
+
+	var rbs []*explorer.RawBlock
+
+	for n, rb := range c.rawBlocks {
+		if n >= fromNumber {
+			rbs = append(rbs, rb)
+		}
+	}
+
+	sort.Slice(rbs, func(i, j int) bool {
+		return rbs[i].Number < rbs[j].Number
+	})
+
+	if len(rbs) > limit {
+		rbs = rbs[:limit]
+	}
+
+	return rbs, nil
+}
+
+func (s *MemoryStorage) ResetChannel(ctx context.Context,
+	channelName string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.channels[channelName]; ok {
+		c.reset()
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) Begin(ctx context.Context) (StorageTx, error) {
+	return &memoryStorageTx{storage: s}, nil
+}
+
+// Block returns stored block of the channel.
+func (s *MemoryStorage) Block(channelName string, number int64) (
+	*DecodedBlock, bool) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[channelName]
+	if !ok {
+		return nil, false
+	}
+
+	b, ok := c.blocks[number]
+	return b, ok
+}
+
+// State returns actual state of the key.
+func (s *MemoryStorage) State(channelName, chaincode, key string) (
+	*explorer.State, bool) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[channelName]
+	if !ok {
+		return nil, false
+	}
+
+	st, ok := c.states[memoryStateKey{chaincode: chaincode, key: key}]
+	return st, ok
+}
+
+// OldStates returns old states of the key in order they were replaced.
+func (s *MemoryStorage) OldStates(channelName, chaincode,
+	key string) []*explorer.OldState {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[channelName]
+	if !ok {
+		return nil
+	}
+
+	return c.oldStates[memoryStateKey{chaincode: chaincode, key: key}]
+}
+
+// FailedBlocks returns failed blocks of the channel in number order.
+func (s *MemoryStorage) FailedBlocks(
+	channelName string) []*explorer.FailedBlock {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[channelName]
+	if !ok {
+		return nil
+	}
+
+	var fbs []*explorer.FailedBlock
+
+	for _, fb := range c.failedBlocks {
+		fbs = append(fbs, fb)
+	}
+
+	sort.Slice(fbs, func(i, j int) bool {
+		return fbs[i].Number < fbs[j].Number
+	})
+
+	return fbs
+}
+
+// memoryStorageTx collects writes and applies them to storage on commit.
+type memoryStorageTx struct {
+	storage *MemoryStorage
+	writes  []func()
+	done    bool
+}
+
+func (tx *memoryStorageTx) WriteBlocks(ctx context.Context,
+	blocks []*DecodedBlock) error {
+
+	if tx.done {
+		return errStorageTxDone
+	}
+
+	for _, b := range blocks {
+		b := b
+		tx.writes = append(tx.writes, func() {
+			tx.storage.writeBlock(b)
+		})
+	}
+
+	return nil
+}
+
+func (tx *memoryStorageTx) WriteFailedBlock(ctx context.Context,
+	channelName string, fb *explorer.FailedBlock,
+	rb *explorer.RawBlock) error {
+
+	if tx.done {
+		return errStorageTxDone
+	}
+
+	tx.writes = append(tx.writes, func() {
+		c := tx.storage.channel(channelName)
+
+		if rb != nil {
+			rb.ChannelId = c.id
+			if _, ok := c.rawBlocks[rb.Number]; !ok {
+				c.rawBlocks[rb.Number] = rb
+			}
+		}
+
+		fb.ChannelId = c.id
+		if prev, ok := c.failedBlocks[fb.Number]; ok {
+			fb.Id = prev.Id
+			fb.Failures += prev.Failures
+		} else {
+			fb.Id = tx.storage.nextID()
+		}
+		c.failedBlocks[fb.Number] = fb
+
+		c.setLastBlockNumber(fb.Number)
+	})
+
+	return nil
+}
+
+func (tx *memoryStorageTx) DeleteFailedBlock(ctx context.Context,
+	channelName string, number int64) error {
+
+	if tx.done {
+		return errStorageTxDone
+	}
+
+	tx.writes = append(tx.writes, func() {
+		delete(tx.storage.channel(channelName).failedBlocks, number)
+	})
+
+	return nil
+}
+
+func (tx *memoryStorageTx) Commit() error {
+	if tx.done {
+		return errStorageTxDone
+	}
+
+	tx.done = true
+
+	tx.storage.mu.Lock()
+	defer tx.storage.mu.Unlock()
+
+	for _, w := range tx.writes {
+		w()
+	}
+
+	return nil
+}
+
+func (tx *memoryStorageTx) Rollback() error {
+	if tx.done {
+		return errStorageTxDone
+	}
+
+	tx.done = true
+	tx.writes = nil
+
+	return nil
+}
+
+// writeBlock stores decoded block and sets IDs of its entities. It must be
+// called with mutex locked.
+func (s *MemoryStorage) writeBlock(b *DecodedBlock) {
+
+	c := s.channel(b.ChannelName)
+
+	b.Block.ChannelId = c.id
+	if prev, ok := c.blocks[b.Block.Number]; ok {
+		b.Block.Id = prev.Block.Id
+	} else {
+		b.Block.Id = s.nextID()
+	}
+	c.blocks[b.Block.Number] = b
+
+	if b.RawBlock != nil {
+		b.RawBlock.ChannelId = c.id
+		if _, ok := c.rawBlocks[b.RawBlock.Number]; !ok {
+			c.rawBlocks[b.RawBlock.Number] = b.RawBlock
+		}
+	}
+
+	for _, t := range b.Transactions {
+		t.ChannelId = c.id
+		t.BlockId = b.Block.Id
+	}
+	for _, a := range b.Arguments {
+		a.BlockId = b.Block.Id
+	}
+	for _, en := range b.Endorsements {
+		en.BlockId = b.Block.Id
+	}
+	for _, r := range b.Reads {
+		r.BlockId = b.Block.Id
+	}
+	for _, rq := range b.RangeQueries {
+		rq.RangeQuery.BlockId = b.Block.Id
+		for _, r := range rq.Reads {
+			r.BlockId = b.Block.Id
+		}
+	}
+
+	for _, sw := range b.States {
+		sw.State.ChannelId = c.id
+
+		k := memoryStateKey{chaincode: sw.State.Chaincode, key: sw.State.Key}
+
+		if c.stateOutdates(k, sw) {
+			old := memoryOldState(sw.State)
+			old.Deleted = sw.IsDelete
+			c.oldStates[k] = append(c.oldStates[k], old)
+			continue
+		}
+
+		prev, exists := c.states[k]
+		if exists {
+			c.oldStates[k] = append(c.oldStates[k], memoryOldState(prev))
+		}
+
+		if !sw.IsDelete {
+			c.states[k] = sw.State
+			continue
+		}
+
+		deleted := memoryOldState(sw.State)
+		deleted.Deleted = true
+		if exists {
+			deleted.Type = prev.Type
+		}
+		c.oldStates[k] = append(c.oldStates[k], deleted)
+
+		delete(c.states, k)
+	}
+
+	c.setLastBlockNumber(b.Block.Number)
+}
+
+// stateOutdates reports whether actual state or the last deletion of the key
+// is of later version than the write.
+func (c *memoryChannel) stateOutdates(k memoryStateKey, sw StateWrite) bool {
+
+	if s, ok := c.states[k]; ok &&
+		sw.Precedes(s.VersionBlockNumber, s.VersionTxNumber) {
+		return true
+	}
+
+	for _, os := range c.oldStates[k] {
+		if os.Deleted &&
+			sw.Precedes(os.VersionBlockNumber, os.VersionTxNumber) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func memoryOldState(s *explorer.State) *explorer.OldState {
+	return &explorer.OldState{
+		ChannelId:     s.ChannelId,
+		TransactionId: s.TransactionId,
+		Key:           s.Key,
+		Chaincode:     s.Chaincode,
+		Type:          s.Type,
+		RawValue:      s.RawValue,
+		Value:         s.Value,
+		CreatedAt:     s.CreatedAt,
+
+		VersionBlockNumber: s.VersionBlockNumber,
+		VersionTxNumber:    s.VersionTxNumber,
+	}
+}
@@ -0,0 +1,57 @@
+package hf_test
+
+import (
+	"testing"
+
+	"explorer/hf"
+	"explorer/hf/storagetest"
+)
+
+const testChannel = "mychannel"
+
+// memoryStates returns states of memory storage.
+func memoryStates(s *hf.MemoryStorage) storagetest.StatesFunc {
+	return func(t *testing.T, channelName, key string) (
+		*storagetest.State, []storagetest.State) {
+
+		var (
+			actual *storagetest.State
+			old    []storagetest.State
+		)
+
+		if st, ok := s.State(channelName, storagetest.Chaincode, key); ok {
+			actual = &storagetest.State{
+				Value:              st.RawValue,
+				VersionBlockNumber: st.VersionBlockNumber,
+				VersionTxNumber:    st.VersionTxNumber,
+			}
+		}
+
+		for _, os := range s.OldStates(channelName, storagetest.Chaincode,
+			key) {
+
+			old = append(old, storagetest.State{
+				Value:              os.RawValue,
+				Deleted:            os.Deleted,
+				VersionBlockNumber: os.VersionBlockNumber,
+				VersionTxNumber:    os.VersionTxNumber,
+			})
+		}
+
+		return actual, old
+	}
+}
+
+func TestMemoryStorageRetriedBlockStates(t *testing.T) {
+	s := hf.NewMemoryStorage()
+	storagetest.TestRetriedBlockStates(t, s, testChannel, memoryStates(s))
+}
+
+func TestMemoryStorageRawBlocks(t *testing.T) {
+	storagetest.TestRawBlocks(t, hf.NewMemoryStorage(), testChannel)
+}
+
+func TestMemoryStorageResetChannel(t *testing.T) {
+	s := hf.NewMemoryStorage()
+	storagetest.TestResetChannel(t, s, testChannel, memoryStates(s))
+}
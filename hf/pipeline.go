@@ -0,0 +1,272 @@
+package hf
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// decodeJob is a block passed through processor pipeline: dispatcher sends
+// it to decode workers and to committer in block order, committer waits
+// until the block is decoded and stores it.
+type decodeJob struct {
+	be      *BlockEvent
+	block   *DecodedBlock
+	err     error
+	decoded chan struct{}
+}
+
+func (p *Processor) blockLog(be *BlockEvent) *logrus.Entry {
+	return p.log.WithFields(logrus.Fields{
+		"block_number": be.Block.Header.Number,
+		"peer_url":     be.SourceURL,
+	})
+}
+
+// dispatch receives blocks from block source and passes them to decode
+// workers and committer. Blocks already passed are skipped, since source may
+// deliver them again after reconnect.
+func (p *Processor) dispatch(bs BlockSource, nextBlockNumber uint64,
+	jobs, ordered chan<- *decodeJob) {
+
+	defer p.wg.Done()
+	defer close(jobs)
+	defer close(ordered)
+	defer bs.Close()
+
+	for {
+		select {
+		case <-p.close:
+			return
+		case <-p.done:
+			return
+		case be, ok := <-bs.BlockEvents():
+			if !ok {
+				return
+			}
+
+			log := p.blockLog(be)
+
+			log.Debug("block received")
+
+			if be.Block.Header.Number < nextBlockNumber {
+				log.Debug("block already processed, skipping")
+				continue
+			}
+
+			nextBlockNumber = be.Block.Header.Number + 1
+
+			j := &decodeJob{
+				be:      be,
+				decoded: make(chan struct{}),
+			}
+
+			select {
+			case <-p.close:
+				return
+			case <-p.done:
+				return
+			case ordered <- j:
+			}
+
+			select {
+			case <-p.close:
+				return
+			case <-p.done:
+				return
+			case jobs <- j:
+			}
+		}
+	}
+}
+
+// decode decodes blocks of jobs until jobs channel is closed.
+func (p *Processor) decode(jobs <-chan *decodeJob) {
+	defer p.wg.Done()
+
+	for j := range jobs {
+		j.block, j.err = p.decodeBlockEvent(p.blockLog(j.be), j.be)
+		close(j.decoded)
+	}
+}
+
+const rateLogInterval = 30 * time.Second
+
+// commit stores decoded blocks in block order and handles retries of failed
+// blocks between them. Blocks already decoded are stored in batches if batch
+// size is greater than one.
+func (p *Processor) commit(ordered <-chan *decodeJob) {
+	defer p.wg.Done()
+	defer close(p.done)
+
+	var (
+		rateBlocks int
+		rateFrom   = time.Now()
+	)
+
+	for {
+		select {
+		case <-p.close:
+			return
+		case r := <-p.retries:
+			r.result <- p.retryBlockEvent(r.be)
+		case j, ok := <-ordered:
+			if !ok {
+				p.log.Info("all blocks of block source processed")
+				return
+			}
+
+			select {
+			case <-p.close:
+				return
+			case <-j.decoded:
+			}
+
+			batch := p.collectBatch(j, ordered)
+
+			if !p.commitBatch(batch) {
+				return
+			}
+
+			rateBlocks += len(batch)
+
+			if d := time.Since(rateFrom); d >= rateLogInterval {
+				p.log.WithFields(logrus.Fields{
+					"blocks":            rateBlocks,
+					"blocks_per_second": float64(rateBlocks) / d.Seconds(),
+				}).Info("processing rate")
+
+				rateBlocks = 0
+				rateFrom = time.Now()
+			}
+		}
+	}
+}
+
+// collectBatch returns batch of the given job and following jobs which are
+// already decoded, up to batch size.
+func (p *Processor) collectBatch(j *decodeJob,
+	ordered <-chan *decodeJob) []*decodeJob {
+
+	batch := []*decodeJob{j}
+
+	for len(batch) < p.batchSize {
+		select {
+		case nj, ok := <-ordered:
+			if !ok {
+				return batch
+			}
+			// Job is taken from the queue, so it is waited to keep order.
+			select {
+			case <-p.close:
+				return batch
+			case <-nj.decoded:
+				batch = append(batch, nj)
+			}
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+// commitBatch stores batch of decoded blocks in one unit of work. If batch can
+// not be stored, blocks are stored one by one, so failed block is found. It
+// returns false if processor is closed or halted.
+func (p *Processor) commitBatch(batch []*decodeJob) bool {
+
+	if len(batch) > 1 {
+		var (
+			blocks = make([]*DecodedBlock, 0, len(batch))
+			err    error
+		)
+
+		for _, j := range batch {
+			if j.err != nil {
+				err = j.err
+				break
+			}
+			blocks = append(blocks, j.block)
+		}
+
+		if err == nil {
+			err = p.writeBlocks(blocks, false)
+			if err == nil {
+				p.log.WithFields(logrus.Fields{
+					"from_block_number": batch[0].be.Block.Header.Number,
+					"to_block_number": batch[len(batch)-1].be.Block.
+						Header.Number,
+				}).Info("blocks processed")
+				return true
+			}
+		}
+
+		p.log.WithError(err).
+			Warning("failed to process blocks batch, processing one by one")
+	}
+
+	for _, j := range batch {
+		if !p.commitJob(j) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// commitJob stores decoded block. Block is decoded and stored again every 10
+// seconds until it is stored or failures limit is reached. It returns false
+// if processor is closed or halted.
+func (p *Processor) commitJob(j *decodeJob) bool {
+
+	var (
+		log      = p.blockLog(j.be)
+		failures int
+		err      = j.err
+	)
+
+	if err == nil {
+		err = p.writeBlocks([]*DecodedBlock{j.block}, false)
+	}
+
+	for {
+		if err == nil {
+			log.Info("block processed")
+			return true
+		}
+
+		failures++
+
+		log.WithError(err).WithField("failures", failures).
+			Error("failed to process block")
+
+		if p.maxBlockFailures > 0 && failures >= p.maxBlockFailures {
+
+			if p.failedBlockPolicy == FailedBlockPolicyHalt {
+				log.Error("block failures limit reached, processor halted")
+				return false
+			}
+
+			err = p.storeFailedBlock(j.be, failures, err)
+			if err == nil {
+				log.Warning("block failures limit reached, " +
+					"block stored as failed and skipped")
+				return true
+			}
+
+			log.WithError(err).Error("failed to store failed block")
+		}
+
+		t := time.NewTimer(10 * time.Second)
+
+		select {
+		case <-p.close:
+			t.Stop()
+			return false
+		case <-t.C:
+		}
+
+		err = p.processBlockEvent(log, j.be, false)
+	}
+}
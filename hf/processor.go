@@ -2,22 +2,14 @@ package hf
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"sync"
-	"time"
 
 	"github.com/davecgh/go-spew/spew"
-	"github.com/dimuls/fabric-sdk-go/pkg/client/event"
-	"github.com/dimuls/fabric-sdk-go/pkg/common/providers/fab"
-	"github.com/dimuls/fabric-sdk-go/pkg/core/config"
-	"github.com/dimuls/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
-	"github.com/dimuls/fabric-sdk-go/pkg/fabsdk"
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric-protos-go/common"
-	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
-	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
 	fabricPeer "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/sirupsen/logrus"
 
@@ -30,150 +22,194 @@ type ProcessorConfig struct {
 	Organization     string   `yaml:"organization"`
 	User             string   `yaml:"user"`
 	FabricConfigFile string   `yaml:"fabric_config_file"`
+	PrivateData      bool     `yaml:"private_data"`
+
+	// RawBlocks enables storing of received blocks, so they can be
+	// reindexed later. CompressRawBlocks enables gzip compression of them.
+	RawBlocks         bool `yaml:"raw_blocks"`
+	CompressRawBlocks bool `yaml:"compress_raw_blocks"`
+
+	// Reindex enables reindex mode: all indexed data of the channel is
+	// removed and rebuilt from stored raw blocks without connecting to
+	// peers. Processor stops when all stored blocks are processed.
+	Reindex bool `yaml:"reindex"`
+
+	// BlockFilesDir enables offline ingestion: blocks are read from Fabric
+	// ledger block files of the directory instead of peers. Processor stops
+	// when all blocks of the files are processed.
+	BlockFilesDir string `yaml:"block_files_dir"`
+
+	// MaxBlockFailures is a number of failed attempts to process a block
+	// after which FailedBlockPolicy is applied, zero means that block is
+	// retried forever. FailedBlockPolicy is "skip" (default) to store the
+	// block as failed and continue with the next one, or "halt" to stop
+	// processing of the channel.
+	MaxBlockFailures  int    `yaml:"max_block_failures"`
+	FailedBlockPolicy string `yaml:"failed_block_policy"`
+
+	// DecodeWorkers is a number of goroutines decoding blocks ahead of
+	// storing them, one is used by default. Blocks are stored in number
+	// order regardless of workers count.
+	DecodeWorkers int `yaml:"decode_workers"`
+
+	// BatchSize is a max number of decoded blocks stored in one unit of work
+	// of storage, blocks are stored one by one if it is zero or one.
+	BatchSize int `yaml:"batch_size"`
 }
 
 type Processor struct {
-	channelName string
-	storage     Storage
-	log         *logrus.Entry
-	wg          sync.WaitGroup
-	close       chan struct{}
-}
-
-type chaincodeEventSource struct {
-	reg fab.Registration
-	es  <-chan *fab.CCEvent
+	channelName       string
+	chaincodes        map[string]bool
+	rawBlocks         bool
+	compressRawBlocks bool
+	maxBlockFailures  int
+	failedBlockPolicy string
+	storage           Storage
+	batchSize         int
+	retries           chan *retryRequest
+	log               *logrus.Entry
+	wg                sync.WaitGroup
+	close             chan struct{}
+	done              chan struct{}
 }
 
 func init() {
 	spew.Config.DisableMethods = true
 }
 
-func NewProcessor(c ProcessorConfig, s Storage) (p *Processor, err error) {
+// NewProcessor returns processor of blocks from the block source selected by
+// the config: stored raw blocks in reindex mode, block files if block files
+// directory is set, or channel peers otherwise.
+func NewProcessor(c ProcessorConfig, s Storage) (*Processor, error) {
+
+	var (
+		bs  BlockSource
+		err error
+	)
+
+	switch {
+	case c.Reindex:
+		bs = NewStorageSource(s, c.ChannelName)
+	case c.BlockFilesDir != "":
+		bs, err = NewFileSource(c.BlockFilesDir)
+		if err != nil {
+			return nil, fmt.Errorf("create file source: %w", err)
+		}
+	default:
+		bs, err = NewFabricSource(c)
+		if err != nil {
+			return nil, fmt.Errorf("create fabric source: %w", err)
+		}
+	}
+
+	p, err := NewProcessorWithSource(c, s, bs)
+	if err != nil {
+		bs.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewProcessorWithSource returns processor of blocks from the given block
+// source. Source is started from the block next to the last processed one
+// and is closed when processor is closed.
+func NewProcessorWithSource(c ProcessorConfig, s Storage, bs BlockSource) (
+	p *Processor, err error) {
 
 	log := logrus.WithFields(logrus.Fields{
 		"subsystem":  "processor",
 		"channel_id": c.ChannelName,
 	})
 
-	lastBlockID, err := s.LastBlockID(context.TODO())
-	if err != nil {
-		return nil, fmt.Errorf(
-			"get last block ID from storage: %w", err)
+	failedBlockPolicy := c.FailedBlockPolicy
+
+	switch failedBlockPolicy {
+	case "":
+		failedBlockPolicy = FailedBlockPolicySkip
+	case FailedBlockPolicySkip, FailedBlockPolicyHalt:
+	default:
+		return nil, fmt.Errorf("unknown failed block policy `%s`",
+			failedBlockPolicy)
 	}
 
-	log.WithField("last_block_id", lastBlockID).Debug("got last block ID")
+	var nextBlockNumber uint64
 
-	fsdk, err := fabsdk.New(config.FromFile(c.FabricConfigFile))
-	if err != nil {
-		return nil, fmt.Errorf("create fabric SDK: %w", err)
-	}
+	if c.Reindex {
+		log.Info("reindex mode, removing indexed channel data")
 
-	chCtx := fsdk.ChannelContext(c.ChannelName,
-		fabsdk.WithUser(c.User))
+		err = s.ResetChannel(context.TODO(), c.ChannelName)
+		if err != nil {
+			return nil, fmt.Errorf("reset channel in storage: %w", err)
+		}
+	} else {
+		lastBlockNumber, found, err := s.LastBlockNumber(context.TODO(),
+			c.ChannelName)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"get last block number from storage: %w", err)
+		}
 
-	evClient, err := event.New(chCtx, event.WithBlockEvents(), event.WithSeekType(seek.FromBlock),
-		event.WithBlockNum(uint64(lastBlockID)))
-	if err != nil {
-		return nil, fmt.Errorf("create event client: %w", err)
+		if found {
+			nextBlockNumber = uint64(lastBlockNumber) + 1
+			log.WithField("last_block_number", lastBlockNumber).
+				Debug("got last block number")
+		} else {
+			log.Debug("no blocks processed yet")
+		}
 	}
 
-	var (
-		bsEsReg fab.Registration
-		bsEs    <-chan *fab.BlockEvent
-	)
-
-	bsEsReg, bsEs, err = evClient.RegisterBlockEvent()
+	err = bs.Start(nextBlockNumber)
 	if err != nil {
-		return nil, fmt.Errorf("register block event: %w", err)
+		return nil, fmt.Errorf("start block source: %w", err)
 	}
-	defer func() {
-		if err != nil {
-			evClient.Unregister(bsEsReg)
+
+	var chaincodes map[string]bool
+
+	if len(c.Chaincodes) > 0 {
+		chaincodes = map[string]bool{}
+		for _, cc := range c.Chaincodes {
+			chaincodes[cc] = true
 		}
-	}()
+	}
 
-	//var ccEvSrcs []chaincodeEventSource
-	//defer func() {
-	//	if err != nil {
-	//		for _, cc := range ccEvSrcs {
-	//			evClient.Unregister(cc.reg)
-	//		}
-	//	}
-	//}()
-
-	//for _, cc := range c.Chaincodes {
-	//	reg, es, err := evClient.RegisterChaincodeEvent(cc, "")
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//	ccEvSrcs = append(ccEvSrcs, chaincodeEventSource{
-	//		reg: reg,
-	//		es:  es,
-	//	})
-	//}
+	decodeWorkers := c.DecodeWorkers
+	if decodeWorkers < 1 {
+		decodeWorkers = 1
+	}
 
 	p = &Processor{
 		channelName: c.ChannelName,
-		storage:     s,
-		log:         log,
-		close:       make(chan struct{}),
+		chaincodes:  chaincodes,
+		// Raw blocks are already stored in reindex mode.
+		rawBlocks:         c.RawBlocks && !c.Reindex,
+		compressRawBlocks: c.CompressRawBlocks,
+		maxBlockFailures:  c.MaxBlockFailures,
+		failedBlockPolicy: failedBlockPolicy,
+		storage:           s,
+		batchSize:         c.BatchSize,
+		retries:           make(chan *retryRequest),
+		log:               log,
+		close:             make(chan struct{}),
+		done:              make(chan struct{}),
 	}
 
-	p.wg.Add(1)
-	go func() {
-		defer p.wg.Done()
-		defer evClient.Unregister(bsEsReg)
-
-		for {
-			select {
-			case <-p.close:
-				return
-			case be := <-bsEs:
-				for {
-					log := p.log.WithFields(logrus.Fields{
-						"block_number": be.Block.Header.Number,
-						"peer_url":     be.SourceURL,
-					})
-
-					log.Debug("block received")
-
-					err := p.processBlockEvent(log, be)
-					if err == nil {
-						log.Info("block processed")
-						break
-					}
-					p.log.WithError(err).Error("failed to process block")
-
-					t := time.NewTimer(10 * time.Second)
-
-					select {
-					case <-p.close:
-						t.Stop()
-						return
-					case <-t.C:
-					}
-				}
-			}
-		}
-	}()
+	var (
+		jobs = make(chan *decodeJob)
+		// Jobs are passed to committer in order of blocks, it bounds
+		// number of blocks decoded ahead of commit.
+		ordered = make(chan *decodeJob, 2*decodeWorkers+c.BatchSize)
+	)
+
+	p.wg.Add(2 + decodeWorkers)
+
+	go p.dispatch(bs, nextBlockNumber, jobs, ordered)
 
-	//for _, ccEvSrc := range ccEvSrcs {
-	//	p.wg.Add(1)
-	//	go func(ccEvSrc chaincodeEventSource) {
-	//		defer p.wg.Done()
-	//		defer client.Unregister(ccEvSrc.reg)
-	//
-	//		for {
-	//			select {
-	//			case <-p.close:
-	//				return
-	//			case ce := <-ccEvSrc.es:
-	//				p.processChaincodeEvent(ce)
-	//			}
-	//		}
-	//	}(ccEvSrc)
-	//}
+	for i := 0; i < decodeWorkers; i++ {
+		go p.decode(jobs)
+	}
+
+	go p.commit(ordered)
 
 	return p, nil
 }
@@ -183,288 +219,313 @@ func (p *Processor) Close() {
 	p.wg.Wait()
 }
 
-const lifecycle = "_lifecycle"
+// ChannelName returns name of the channel processed by processor.
+func (p *Processor) ChannelName() string {
+	return p.channelName
+}
 
-func (p *Processor) processBlockEvent(log *logrus.Entry, be *fab.BlockEvent) error {
+// processBlockEvent decodes block and stores it in storage. Failed block is
+// removed from storage if retry is set.
+func (p *Processor) processBlockEvent(log *logrus.Entry, be *BlockEvent,
+	retry bool) error {
+
+	b, err := p.decodeBlockEvent(log, be)
+	if err != nil {
+		return err
+	}
+
+	return p.writeBlocks([]*DecodedBlock{b}, retry)
+}
+
+// decodeBlockEvent decodes block with its transactions. It does not use
+// storage, so blocks can be decoded concurrently. Panic of decoding malformed
+// block is returned as error, so block is handled as failed block.
+func (p *Processor) decodeBlockEvent(log *logrus.Entry, be *BlockEvent) (
+	_ *DecodedBlock, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("decode block panicked: %v", r)
+		}
+	}()
+
+	if be.decodeErr != nil {
+		return nil, be.decodeErr
+	}
 
 	var (
-		peer           = &explorer.Peer{}
-		channel        = &explorer.Channel{}
-		channelConfigs []*explorer.ChannelConfig
+		channelConfigs []*ChannelConfig
 		chaincodes     []*explorer.Chaincode
-		block          = &explorer.Block{}
+		block          = decodeBlock(be.Block)
 		transactions   []*explorer.Transaction
-		states         []*explorer.State
+		creators       []*explorer.Identity
+		arguments      []*explorer.Argument
+		reads          []*explorer.Read
+		rangeQueries   []RangeQuery
+		states         []StateWrite
+
+		privateWriteHashes []*explorer.PrivateWriteHash
+		privateWrites      []*explorer.PrivateWrite
+		chaincodeEvents    []*explorer.ChaincodeEvent
+		endorsements       []*explorer.Endorsement
+
+		chaincodeDefinitions []*ChaincodeDefinitionChange
 	)
 
 	if be.Block.Header.Number > uint64(math.MaxInt64) {
-		return fmt.Errorf("block number greater than max int64")
+		return nil, fmt.Errorf("block number greater than max int64")
+	}
+
+	txsFilter, err := transactionsFilter(be.Block)
+	if err != nil {
+		return nil, fmt.Errorf("get transactions filter: %w", err)
 	}
 
-	for _, d := range be.Block.Data.Data {
+	metadata, err := decodeBlockMetadata(be.Block)
+	if err != nil {
+		log.WithError(err).Warning("failed to decode block metadata")
+		metadata = &blockMetadata{}
+	}
+
+	block.LastConfigIndex = metadata.lastConfigIndex
+	block.CommitHash = hex.EncodeToString(metadata.commitHash)
+
+	for i, d := range be.Block.GetData().GetData() {
 
 		envelope := &common.Envelope{}
 		err := proto.Unmarshal(d, envelope)
 		if err != nil {
-			return fmt.Errorf("unmarshal envelope: %w", err)
+			return nil, fmt.Errorf("unmarshal envelope: %w", err)
 		}
 
 		payload := &common.Payload{}
 		err = proto.Unmarshal(envelope.Payload, payload)
 		if err != nil {
-			return fmt.Errorf("unmarshal payload: %w", err)
+			return nil, fmt.Errorf("unmarshal payload: %w", err)
 		}
 
 		channelHeader := &common.ChannelHeader{}
-		err = proto.Unmarshal(payload.Header.ChannelHeader, channelHeader)
+		err = proto.Unmarshal(payload.GetHeader().GetChannelHeader(),
+			channelHeader)
 		if err != nil {
-			return fmt.Errorf("unmarshal channel header: %w", err)
+			return nil, fmt.Errorf("unmarshal channel header: %w", err)
 		}
 
 		transaction := &explorer.Transaction{}
 
 		transaction.Id = channelHeader.TxId
+		transaction.Number = int32(i)
 		transaction.BlockId = block.Id
 		transaction.CreatedAt = channelHeader.Timestamp
-
-		transactions = append(transactions, transaction)
-
-		log.WithField("transaction_type",
-			common.HeaderType(channelHeader.Type)).
-			Debug("transaction found")
+		transaction.ValidationCode = txsFilter[i].String()
 
 		headerType := common.HeaderType(channelHeader.Type)
 
-		switch headerType {
+		transaction.Type = headerType.String()
 
-		case common.HeaderType_MESSAGE,
-			common.HeaderType_CONFIG_UPDATE,
-			common.HeaderType_ORDERER_TRANSACTION,
-			common.HeaderType_DELIVER_SEEK_INFO,
-			common.HeaderType_CHAINCODE_PACKAGE:
+		creator, err := decodeCreator(payload.Header)
+		if err != nil {
+			log.WithError(err).WithField("transaction_id", transaction.Id).
+				Warning("failed to decode transaction creator")
+		}
 
-			return fmt.Errorf(
-				"transactions of type `%s` not implemented", headerType)
+		transactions = append(transactions, transaction)
+		creators = append(creators, creator)
 
-		case common.HeaderType_CONFIG:
+		log.WithFields(logrus.Fields{
+			"transaction_type": transaction.Type,
+			"validation_code":  transaction.ValidationCode,
+		}).Debug("transaction found")
 
-			channelConfig := &explorer.ChannelConfig{}
+		valid := txsFilter[i] == fabricPeer.TxValidationCode_VALID
 
-			channelConfig.CreatedAt = channelHeader.Timestamp
-			channelConfig.Raw = payload.Data
+		switch headerType {
 
-			configEnvelope := &common.ConfigEnvelope{}
+		case common.HeaderType_CONFIG:
 
-			err := proto.Unmarshal(payload.Data, configEnvelope)
-			if err != nil {
-				return fmt.Errorf("parse channel config: %w", err)
+			if !valid {
+				continue
 			}
 
-			channelConfig.Parsed, err = json.Marshal(configEnvelope.Config)
+			cc, err := decodeChannelConfig(log, channelHeader,
+				payload)
 			if err != nil {
-				return fmt.Errorf("JSON marshal config: %w", err)
+				return nil, fmt.Errorf("decode channel config: %w", err)
 			}
 
-			channelConfigs = append(channelConfigs, channelConfig)
+			channelConfigs = append(channelConfigs, cc)
 
 		case common.HeaderType_ENDORSER_TRANSACTION:
 
-			channelHeaderExtension := &fabricPeer.ChaincodeHeaderExtension{}
-
-			err = proto.Unmarshal(channelHeader.Extension, channelHeaderExtension)
+			et, err := decodeEndorserTransaction(log, transaction,
+				channelHeader, payload)
 			if err != nil {
-				return fmt.Errorf(
-					"unmarshal channel header extension: %w", err)
+				if !valid {
+					log.WithError(err).Warning(
+						"failed to decode invalid endorser transaction")
+					continue
+				}
+				return nil, fmt.Errorf("decode endorser transaction: %w", err)
 			}
 
-			chaincode := &explorer.Chaincode{}
-
-			chaincode.Name = channelHeaderExtension.ChaincodeId.Name
-			chaincode.Version = channelHeaderExtension.ChaincodeId.Version
+			transaction.Function = et.function
 
-			chaincodes = append(chaincodes, chaincode)
+			chaincodes = append(chaincodes, et.chaincode)
+			arguments = append(arguments, et.arguments...)
+			reads = append(reads, et.reads...)
+			rangeQueries = append(rangeQueries, et.rangeQueries...)
+			endorsements = append(endorsements, et.endorsements...)
 
-			fabricTransaction := &fabricPeer.Transaction{}
+			if !valid {
+				continue
+			}
 
-			err = proto.Unmarshal(payload.Data, fabricTransaction)
-			if err != nil {
-				return fmt.Errorf("unmarshal transaction: %w", err)
+			for _, s := range et.states {
+				s.State.VersionBlockNumber = block.Number
+				s.State.VersionTxNumber = int64(transaction.Number)
 			}
 
-			for _, a := range fabricTransaction.Actions {
-				chaincodeActionPayload := &fabricPeer.ChaincodeActionPayload{}
+			states = append(states, et.states...)
 
-				err = proto.Unmarshal(a.Payload, chaincodeActionPayload)
-				if err != nil {
-					return fmt.Errorf(
-						"unmarshal chaincode action payload: %w", err)
+			for _, ce := range et.chaincodeEvents {
+				if p.chaincodes == nil || p.chaincodes[ce.Chaincode] {
+					chaincodeEvents = append(chaincodeEvents, ce)
 				}
+			}
 
-				//chaincodeProposalPayload := &fabricPeer.ChaincodeProposalPayload{}
-				//
-				//err = proto.Unmarshal(
-				//	chaincodeActionPayload.ChaincodeProposalPayload,
-				//	chaincodeProposalPayload)
-				//if err != nil {
-				//	return fmt.Errorf(
-				//		"unmarshal chaincode proposal payload: %w", err)
-				//}
-				//
-				//chaincodeInvocationSpec := &fabricPeer.ChaincodeInvocationSpec{}
-				//
-				//err = proto.Unmarshal(
-				//	chaincodeProposalPayload.Input,
-				//	chaincodeInvocationSpec)
-				//if err != nil {
-				//	return fmt.Errorf(
-				//		"unmarshal chaincode invocation spec: %w", err)
-				//}
-				//
-				//if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeId.Name == lifecycle {
-				//	p.log.WithField("chaincode_id", lifecycle).
-				//		Warning("transaction of unimplemented chaincode found")
-				//	continue
-				//}
-
-				proposalResponsePayload := &fabricPeer.ProposalResponsePayload{}
-
-				err = proto.Unmarshal(
-					chaincodeActionPayload.Action.ProposalResponsePayload,
-					proposalResponsePayload)
-				if err != nil {
-					return fmt.Errorf(
-						"unmarshal proposal response payload: %w", err)
-				}
+			privateWriteHashes = append(privateWriteHashes,
+				et.privateWriteHashes...)
 
-				chaincodeAction := &fabricPeer.ChaincodeAction{}
-				err = proto.Unmarshal(
-					proposalResponsePayload.Extension,
-					chaincodeAction)
+			if et.chaincode.Name == lifecycle {
+				cdc, err := decodeLifecycle(transaction, creator,
+					et.function, et.arguments)
 				if err != nil {
-					return fmt.Errorf(
-						"unmarshal chaincode action: %w", err)
+					log.WithError(err).WithField("transaction_id",
+						transaction.Id).Warning(
+						"failed to decode lifecycle transaction")
+				} else if cdc != nil {
+					chaincodeDefinitions = append(chaincodeDefinitions, cdc)
 				}
+			}
 
-				txReadWriteSet := &rwset.TxReadWriteSet{}
-				err = proto.Unmarshal(
-					chaincodeAction.Results,
-					txReadWriteSet)
+			if pvtRWSet, exists := be.PrivateData[uint64(i)]; exists {
+				pws, err := decodePrivateWrites(log, transaction, pvtRWSet)
 				if err != nil {
-					return fmt.Errorf(
-						"unmarshal transaction read write set: %w", err)
-				}
-
-				for _, rw := range txReadWriteSet.NsRwset {
-					kvRWSet := &kvrwset.KVRWSet{}
-					err = proto.Unmarshal(rw.Rwset, kvRWSet)
-					if err != nil {
-						return fmt.Errorf(
-							"unmarshal kv rw set: %w", err)
-					}
-
-					for _, w := range kvRWSet.Writes {
-						stateType, parsedValue, err := parseValue(chaincode.Name, w.Key, w.Value)
-						if err != nil {
-							fmt.Errorf("parse value: %w", err)
-						}
-
-						states = append(states, &explorer.State{
-							Key:           w.Key,
-							CreatedAt:     transaction.CreatedAt,
-							Type:          stateType,
-							TransactionId: transaction.Id,
-							RawValue:      w.Value,
-							Value:         parsedValue,
-						})
-					}
+					return nil, fmt.Errorf("decode private writes: %w", err)
 				}
+				privateWrites = append(privateWrites, pws...)
 			}
-		}
-	}
-
-	ctx := context.TODO()
 
-	tx, err := p.storage.BeginTx(ctx)
-	if err != nil {
-		return fmt.Errorf("begin transaction in storage: %w", err)
-	}
+		default:
 
-	peer.Url = be.SourceURL
-	peer.Id, err = p.storage.AddPeerTx(ctx, tx, peer)
-	if err != nil {
-		return fmt.Errorf("add peer to storage: %w", err)
-	}
+			// Transactions of other types are stored with raw payload
+			// only, so they are not lost and do not stop block processing.
+			transaction.RawPayload = payload.Data
 
-	channel.Name = p.channelName
-	channel.Id, err = p.storage.AddChannelTx(ctx, tx, channel)
-	if err != nil {
-		return fmt.Errorf("add channel to storage: %w", err)
+			log.WithField("transaction_type", transaction.Type).
+				Debug("transaction of not decoded type stored raw")
+		}
 	}
 
-	err = p.storage.AddPeerChannelTx(ctx, tx, &explorer.PeerChannel{
-		PeerId:    peer.Id,
-		ChannelId: channel.Id,
-	})
-	if err != nil {
-		return fmt.Errorf("add peer_channel to storage: %w", err)
+	b := &DecodedBlock{
+		ChannelName:    p.channelName,
+		SourceURL:      be.SourceURL,
+		Block:          block,
+		Signers:        metadata.signers,
+		ChannelConfigs: channelConfigs,
+		Chaincodes:     chaincodes,
+		Transactions:   transactions,
+		Creators:       creators,
+		Arguments:      arguments,
+		Reads:          reads,
+		RangeQueries:   rangeQueries,
+		States:         states,
+
+		PrivateWriteHashes: privateWriteHashes,
+		PrivateWrites:      privateWrites,
+		ChaincodeEvents:    chaincodeEvents,
+		Endorsements:       endorsements,
+
+		ChaincodeDefinitions: chaincodeDefinitions,
 	}
 
-	for _, cc := range channelConfigs {
-		cc.ChannelId = channel.Id
-		err = p.storage.AddChannelConfigTx(ctx, tx, cc)
+	if p.rawBlocks {
+		b.RawBlock, err = encodeRawBlock(be, p.compressRawBlocks)
 		if err != nil {
-			return fmt.Errorf("add channel config to storage: %w", err)
+			return nil, fmt.Errorf("encode raw block: %w", err)
 		}
 	}
 
-	for _, c := range chaincodes {
-		c.Id, err = p.storage.AddChaincodeTx(ctx, tx, c)
-		if err != nil {
-			return fmt.Errorf("add chaincode to storage: %w", err)
-		}
+	return b, nil
+}
 
-		err = p.storage.AddChannelChaincodeTx(ctx, tx, &explorer.ChannelChaincode{
-			ChannelId:   channel.Id,
-			ChaincodeId: c.Id,
-		})
-		if err != nil {
-			return fmt.Errorf("add channel_chaincode to storage: %w", err)
-		}
-	}
+// writeBlocks stores decoded blocks in one unit of work of storage. Failed
+// blocks of retried blocks are deleted in the same unit of work.
+func (p *Processor) writeBlocks(blocks []*DecodedBlock, retry bool) (
+	err error) {
+
+	ctx := context.TODO()
 
-	block.Number = int64(be.Block.Header.Number)
-	block.ChannelId = channel.Id
-	block.Id, err = p.storage.AddBlockTx(ctx, tx, block)
+	tx, err := p.storage.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("add block to storage: %w", err)
+		return fmt.Errorf("begin storage transaction: %w", err)
 	}
 
-	for _, t := range transactions {
-		t.ChannelId = channel.Id
-		t.BlockId = block.Id
-		err = p.storage.AddTransactionTx(ctx, tx, t)
+	defer func() {
 		if err != nil {
-			return fmt.Errorf("add transaction to storage: %w", err)
+			err2 := tx.Rollback()
+			if err2 != nil {
+				p.log.WithError(err2).
+					Error("failed to rollback storage transaction")
+			}
 		}
+	}()
+
+	err = tx.WriteBlocks(ctx, blocks)
+	if err != nil {
+		return fmt.Errorf("write blocks to storage: %w", err)
 	}
 
-	for _, s := range states {
-		s.ChannelId = channel.Id
-		err = p.storage.AddStateTx(ctx, tx, s)
-		if err != nil {
-			return fmt.Errorf("add state to storage: %w", err)
+	if retry {
+		for _, b := range blocks {
+			err = tx.DeleteFailedBlock(ctx, b.ChannelName, b.Block.Number)
+			if err != nil {
+				return fmt.Errorf(
+					"delete failed block from storage: %w", err)
+			}
 		}
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("commit transaction: %w", err)
+		return fmt.Errorf("commit storage transaction: %w", err)
 	}
 
 	return nil
 }
 
-//func (p *Processor) processChaincodeEvent(ce *fab.CCEvent) error {
-//	// TODO
-//	return nil
-//}
+// transactionsFilter returns validation codes of block transactions set by
+// committing peer.
+func transactionsFilter(b *common.Block) ([]fabricPeer.TxValidationCode, error) {
+
+	metadata := b.GetMetadata().GetMetadata()
+
+	if len(metadata) <= int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		return nil, fmt.Errorf("no transactions filter in block metadata")
+	}
+
+	rawFilter := metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+
+	if len(rawFilter) != len(b.GetData().GetData()) {
+		return nil, fmt.Errorf(
+			"transactions filter length %d not equal to transactions count %d",
+			len(rawFilter), len(b.GetData().GetData()))
+	}
+
+	filter := make([]fabricPeer.TxValidationCode, len(rawFilter))
+	for i, c := range rawFilter {
+		filter[i] = fabricPeer.TxValidationCode(c)
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,366 @@
+package hf
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	fabricPeer "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const testChannel = "mychannel"
+
+// testTx is an endorser transaction of test block, which writes keys of
+// basic chaincode.
+type testTx struct {
+	id     string
+	code   fabricPeer.TxValidationCode
+	writes []*kvrwset.KVWrite
+}
+
+func put(key, value string) *kvrwset.KVWrite {
+	return &kvrwset.KVWrite{Key: key, Value: []byte(value)}
+}
+
+func del(key string) *kvrwset.KVWrite {
+	return &kvrwset.KVWrite{Key: key, IsDelete: true}
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func testTxEnvelope(t *testing.T, tx testTx) []byte {
+	t.Helper()
+
+	chaincodeID := &fabricPeer.ChaincodeID{Name: "basic", Version: "1"}
+
+	results := mustMarshal(t, &rwset.TxReadWriteSet{
+		DataModel: rwset.TxReadWriteSet_KV,
+		NsRwset: []*rwset.NsReadWriteSet{{
+			Namespace: "basic",
+			Rwset:     mustMarshal(t, &kvrwset.KVRWSet{Writes: tx.writes}),
+		}},
+	})
+
+	proposalPayload := mustMarshal(t, &fabricPeer.ChaincodeProposalPayload{
+		Input: mustMarshal(t, &fabricPeer.ChaincodeInvocationSpec{
+			ChaincodeSpec: &fabricPeer.ChaincodeSpec{
+				ChaincodeId: chaincodeID,
+				Input: &fabricPeer.ChaincodeInput{
+					Args: [][]byte{[]byte("Write")},
+				},
+			},
+		}),
+	})
+
+	actionPayload := mustMarshal(t, &fabricPeer.ChaincodeActionPayload{
+		ChaincodeProposalPayload: proposalPayload,
+		Action: &fabricPeer.ChaincodeEndorsedAction{
+			ProposalResponsePayload: mustMarshal(t,
+				&fabricPeer.ProposalResponsePayload{
+					Extension: mustMarshal(t, &fabricPeer.ChaincodeAction{
+						Results:     results,
+						ChaincodeId: chaincodeID,
+					}),
+				}),
+		},
+	})
+
+	channelHeader := mustMarshal(t, &common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: testChannel,
+		TxId:      tx.id,
+		Extension: mustMarshal(t, &fabricPeer.ChaincodeHeaderExtension{
+			ChaincodeId: chaincodeID,
+		}),
+	})
+
+	return mustMarshal(t, &common.Envelope{
+		Payload: mustMarshal(t, &common.Payload{
+			Header: &common.Header{ChannelHeader: channelHeader},
+			Data: mustMarshal(t, &fabricPeer.Transaction{
+				Actions: []*fabricPeer.TransactionAction{{
+					Payload: actionPayload,
+				}},
+			}),
+		}),
+	})
+}
+
+func testBlock(t *testing.T, number uint64, txs ...testTx) *BlockEvent {
+	t.Helper()
+
+	var (
+		data   [][]byte
+		filter []byte
+	)
+
+	for _, tx := range txs {
+		data = append(data, testTxEnvelope(t, tx))
+		filter = append(filter, byte(tx.code))
+	}
+
+	metadata := make([][]byte, len(common.BlockMetadataIndex_name))
+	metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = filter
+
+	return &BlockEvent{
+		Block: &common.Block{
+			Header:   &common.BlockHeader{Number: number},
+			Data:     &common.BlockData{Data: data},
+			Metadata: &common.BlockMetadata{Metadata: metadata},
+		},
+		SourceURL: "memory",
+	}
+}
+
+// testBrokenBlock returns block without metadata, which processor fails to
+// decode.
+func testBrokenBlock(t *testing.T, number uint64) *BlockEvent {
+	t.Helper()
+
+	be := testBlock(t, number, testTx{id: "broken", writes: []*kvrwset.KVWrite{
+		put("broken", "1"),
+	}})
+	be.Block.Metadata = nil
+
+	return be
+}
+
+// runProcessor processes blocks of memory source until all blocks are
+// processed or processor halts.
+func runProcessor(t *testing.T, c ProcessorConfig, s Storage,
+	blocks ...*BlockEvent) {
+
+	t.Helper()
+
+	c.ChannelName = testChannel
+
+	p, err := NewProcessorWithSource(c, s, NewMemorySource(blocks...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	select {
+	case <-p.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("blocks are not processed in time")
+	}
+}
+
+func checkState(t *testing.T, s *MemoryStorage, key, value string) {
+	t.Helper()
+
+	st, ok := s.State(testChannel, "basic", key)
+	if !ok {
+		t.Errorf("no state of `%s`, want `%s`", key, value)
+		return
+	}
+	if string(st.RawValue) != value {
+		t.Errorf("got state of `%s` `%s`, want `%s`", key, st.RawValue,
+			value)
+	}
+}
+
+func checkLastBlockNumber(t *testing.T, s Storage, want int64) {
+	t.Helper()
+
+	n, found, err := s.LastBlockNumber(context.Background(), testChannel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || n != want {
+		t.Errorf("got last block number %d (found %t), want %d", n, found,
+			want)
+	}
+}
+
+func TestProcessorStates(t *testing.T) {
+	s := NewMemoryStorage()
+
+	runProcessor(t, ProcessorConfig{}, s,
+		testBlock(t, 0, testTx{id: "tx0", writes: []*kvrwset.KVWrite{
+			put("a", "a0"), put("b", "b0"),
+		}}),
+		testBlock(t, 1, testTx{id: "tx1", writes: []*kvrwset.KVWrite{
+			put("a", "a1"), del("b"),
+		}}, testTx{
+			id:     "tx2",
+			code:   fabricPeer.TxValidationCode_MVCC_READ_CONFLICT,
+			writes: []*kvrwset.KVWrite{put("a", "invalid"), put("c", "c2")},
+		}))
+
+	checkLastBlockNumber(t, s, 1)
+
+	b, ok := s.Block(testChannel, 1)
+	if !ok {
+		t.Fatal("block 1 is not stored")
+	}
+	if len(b.Transactions) != 2 || b.Transactions[1].Id != "tx2" ||
+		b.Transactions[1].ValidationCode != "MVCC_READ_CONFLICT" {
+		t.Errorf("got transactions %v, want invalid tx2 stored",
+			b.Transactions)
+	}
+
+	// Writes of invalid transaction are not applied.
+	checkState(t, s, "a", "a1")
+
+	if _, ok := s.State(testChannel, "basic", "c"); ok {
+		t.Error("got state of `c` written by invalid transaction")
+	}
+
+	// Deleted state is moved to old states with deletion record.
+	if _, ok := s.State(testChannel, "basic", "b"); ok {
+		t.Error("got state of deleted `b`")
+	}
+
+	oss := s.OldStates(testChannel, "basic", "b")
+	if len(oss) != 2 || string(oss[0].RawValue) != "b0" || oss[0].Deleted ||
+		!oss[1].Deleted || oss[1].TransactionId != "tx1" {
+		t.Errorf("got old states of `b` %v, want b0 and its deletion", oss)
+	}
+
+	oss = s.OldStates(testChannel, "basic", "a")
+	if len(oss) != 1 || string(oss[0].RawValue) != "a0" {
+		t.Errorf("got old states of `a` %v, want a0", oss)
+	}
+}
+
+func TestProcessorResume(t *testing.T) {
+	s := NewMemoryStorage()
+
+	runProcessor(t, ProcessorConfig{}, s,
+		testBlock(t, 0, testTx{id: "tx0", writes: []*kvrwset.KVWrite{
+			put("a", "a0"),
+		}}),
+		testBlock(t, 1, testTx{id: "tx1", writes: []*kvrwset.KVWrite{
+			put("a", "a1"),
+		}}))
+
+	checkLastBlockNumber(t, s, 1)
+
+	// Source delivers blocks from the beginning, processed blocks are not
+	// processed again.
+	runProcessor(t, ProcessorConfig{}, s,
+		testBrokenBlock(t, 0),
+		testBrokenBlock(t, 1),
+		testBlock(t, 2, testTx{id: "tx2", writes: []*kvrwset.KVWrite{
+			put("a", "a2"),
+		}}))
+
+	checkLastBlockNumber(t, s, 2)
+	checkState(t, s, "a", "a2")
+
+	if fbs := s.FailedBlocks(testChannel); len(fbs) != 0 {
+		t.Errorf("got failed blocks %v of already processed blocks", fbs)
+	}
+
+	b, ok := s.Block(testChannel, 0)
+	if !ok || b.Transactions[0].Id != "tx0" {
+		t.Errorf("got block 0 %v, want block stored by the first run", b)
+	}
+}
+
+func TestProcessorBatches(t *testing.T) {
+	s := NewMemoryStorage()
+
+	var blocks []*BlockEvent
+
+	for i := 0; i < 20; i++ {
+		blocks = append(blocks, testBlock(t, uint64(i), testTx{
+			id:     fmt.Sprintf("tx%d", i),
+			writes: []*kvrwset.KVWrite{put("k", fmt.Sprintf("k%d", i))},
+		}))
+	}
+
+	runProcessor(t, ProcessorConfig{BatchSize: 4, DecodeWorkers: 3}, s,
+		blocks...)
+
+	checkLastBlockNumber(t, s, 19)
+	checkState(t, s, "k", "k19")
+
+	// Old states are in order of blocks, regardless of decode order.
+	oss := s.OldStates(testChannel, "basic", "k")
+	if len(oss) != 19 {
+		t.Fatalf("got %d old states, want 19", len(oss))
+	}
+	for i, os := range oss {
+		if want := fmt.Sprintf("k%d", i); string(os.RawValue) != want {
+			t.Errorf("got old state %d `%s`, want `%s`", i, os.RawValue,
+				want)
+		}
+	}
+}
+
+func TestProcessorFailedBlockSkip(t *testing.T) {
+	s := NewMemoryStorage()
+
+	runProcessor(t, ProcessorConfig{
+		MaxBlockFailures: 1,
+		BatchSize:        3,
+	}, s,
+		testBlock(t, 0, testTx{id: "tx0", writes: []*kvrwset.KVWrite{
+			put("a", "a0"),
+		}}),
+		testBrokenBlock(t, 1),
+		testBlock(t, 2, testTx{id: "tx2", writes: []*kvrwset.KVWrite{
+			put("a", "a2"),
+		}}))
+
+	checkLastBlockNumber(t, s, 2)
+	checkState(t, s, "a", "a2")
+
+	if _, ok := s.Block(testChannel, 1); ok {
+		t.Error("failed block 1 is stored as processed")
+	}
+
+	fbs := s.FailedBlocks(testChannel)
+	if len(fbs) != 1 || fbs[0].Number != 1 || fbs[0].Failures != 1 ||
+		fbs[0].Error == "" {
+		t.Errorf("got failed blocks %v, want block 1", fbs)
+	}
+
+	if _, ok := s.State(testChannel, "basic", "broken"); ok {
+		t.Error("got state written by failed block")
+	}
+}
+
+func TestProcessorFailedBlockHalt(t *testing.T) {
+	s := NewMemoryStorage()
+
+	runProcessor(t, ProcessorConfig{
+		MaxBlockFailures:  1,
+		FailedBlockPolicy: FailedBlockPolicyHalt,
+	}, s,
+		testBlock(t, 0, testTx{id: "tx0", writes: []*kvrwset.KVWrite{
+			put("a", "a0"),
+		}}),
+		testBrokenBlock(t, 1),
+		testBlock(t, 2, testTx{id: "tx2", writes: []*kvrwset.KVWrite{
+			put("a", "a2"),
+		}}))
+
+	checkLastBlockNumber(t, s, 0)
+	checkState(t, s, "a", "a0")
+
+	if _, ok := s.Block(testChannel, 2); ok {
+		t.Error("block after failed block is stored")
+	}
+
+	if fbs := s.FailedBlocks(testChannel); len(fbs) != 0 {
+		t.Errorf("got failed blocks %v, want none", fbs)
+	}
+}
@@ -0,0 +1,206 @@
+package hf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	fabricPeer "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/sirupsen/logrus"
+
+	"explorer"
+)
+
+const (
+	rawBlockCompressionNone = "none"
+	rawBlockCompressionGzip = "gzip"
+)
+
+// encodeRawBlock marshals block with its private data, so it can be decoded
+// later again without fetching it from peer.
+func encodeRawBlock(be *BlockEvent, compress bool) (*explorer.RawBlock, error) {
+
+	data, err := proto.Marshal(&fabricPeer.BlockAndPrivateData{
+		Block:          be.Block,
+		PrivateDataMap: be.PrivateData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal block and private data: %w", err)
+	}
+
+	rb := &explorer.RawBlock{
+		Number:      int64(be.Block.Header.Number),
+		Compression: rawBlockCompressionNone,
+		SourceUrl:   be.SourceURL,
+		Data:        data,
+	}
+
+	if compress {
+		var buf bytes.Buffer
+
+		w := gzip.NewWriter(&buf)
+
+		_, err = w.Write(data)
+		if err != nil {
+			return nil, fmt.Errorf("gzip block: %w", err)
+		}
+
+		err = w.Close()
+		if err != nil {
+			return nil, fmt.Errorf("gzip block: %w", err)
+		}
+
+		rb.Compression = rawBlockCompressionGzip
+		rb.Data = buf.Bytes()
+	}
+
+	return rb, nil
+}
+
+func decodeRawBlock(rb *explorer.RawBlock) (*BlockEvent, error) {
+
+	data := rb.Data
+
+	switch rb.Compression {
+	case rawBlockCompressionNone:
+	case rawBlockCompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(rb.Data))
+		if err != nil {
+			return nil, fmt.Errorf("create gzip reader: %w", err)
+		}
+
+		data, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("gunzip block: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression `%s`", rb.Compression)
+	}
+
+	bpd := &fabricPeer.BlockAndPrivateData{}
+
+	err := proto.Unmarshal(data, bpd)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal block and private data: %w", err)
+	}
+
+	if bpd.Block == nil || bpd.Block.Header == nil {
+		return nil, fmt.Errorf("block not found in raw block")
+	}
+
+	return &BlockEvent{
+		Block:       bpd.Block,
+		PrivateData: bpd.PrivateDataMap,
+		SourceURL:   rb.SourceUrl,
+	}, nil
+}
+
+const rawBlocksBatchSize = 100
+
+// storageSource delivers raw blocks stored in storage in number order. Block
+// events channel is closed when all stored blocks are delivered.
+type storageSource struct {
+	storage     Storage
+	channelName string
+	fromNumber  int64
+	events      chan *BlockEvent
+	log         *logrus.Entry
+	wg          sync.WaitGroup
+	close       chan struct{}
+}
+
+// NewStorageSource returns block source delivering raw blocks of the channel
+// stored in the given storage.
+func NewStorageSource(s Storage, channelName string) BlockSource {
+	return &storageSource{
+		storage:     s,
+		channelName: channelName,
+		events:      make(chan *BlockEvent),
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem":  "block_source",
+			"channel_id": channelName,
+			"source":     "storage",
+		}),
+		close: make(chan struct{}),
+	}
+}
+
+func (s *storageSource) Start(fromBlock uint64) error {
+	s.fromNumber = int64(fromBlock)
+
+	s.wg.Add(1)
+	go s.run()
+
+	return nil
+}
+
+func (s *storageSource) run() {
+	defer s.wg.Done()
+	defer close(s.events)
+
+	for {
+		rbs, err := s.storage.RawBlocks(context.TODO(), s.channelName,
+			s.fromNumber, rawBlocksBatchSize)
+		if err != nil {
+			s.log.WithError(err).Error("failed to get raw blocks")
+
+			t := time.NewTimer(10 * time.Second)
+
+			select {
+			case <-s.close:
+				t.Stop()
+				return
+			case <-t.C:
+				continue
+			}
+		}
+
+		if len(rbs) == 0 {
+			return
+		}
+
+		for _, rb := range rbs {
+			s.fromNumber = rb.Number + 1
+
+			be, err := decodeRawBlock(rb)
+			if err != nil {
+				s.log.WithError(err).WithField("block_number", rb.Number).
+					Error("failed to decode raw block")
+
+				// Block is passed to processor with decoding error, so
+				// failed block policy is applied to it.
+				be = &BlockEvent{
+					Block: &common.Block{
+						Header: &common.BlockHeader{
+							Number: uint64(rb.Number),
+						},
+					},
+					SourceURL: rb.SourceUrl,
+					rawBlock:  rb,
+					decodeErr: fmt.Errorf("decode raw block: %w", err),
+				}
+			}
+
+			select {
+			case <-s.close:
+				return
+			case s.events <- be:
+			}
+		}
+	}
+}
+
+func (s *storageSource) BlockEvents() <-chan *BlockEvent {
+	return s.events
+}
+
+func (s *storageSource) Close() {
+	close(s.close)
+	s.wg.Wait()
+}
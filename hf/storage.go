@@ -2,20 +2,37 @@ package hf
 
 import (
 	"context"
-	"database/sql"
+
 	"explorer"
 )
 
 type Storage interface {
-	LastBlockID(ctx context.Context) (lastID int64, err error)
-	BeginTx(ctx context.Context) (*sql.Tx, error)
-	AddPeerTx(ctx context.Context, tx *sql.Tx, p *explorer.Peer) (id int64, err error)
-	AddChannelTx(ctx context.Context, tx *sql.Tx, c *explorer.Channel) (int64, error)
-	AddChannelConfigTx(ctx context.Context, tx *sql.Tx, cc *explorer.ChannelConfig) error
-	AddPeerChannelTx(ctx context.Context, tx *sql.Tx, c *explorer.PeerChannel) error
-	AddChaincodeTx(ctx context.Context, tx *sql.Tx, c *explorer.Chaincode) (id int64, err error)
-	AddChannelChaincodeTx(ctx context.Context, tx *sql.Tx, cc *explorer.ChannelChaincode) error
-	AddBlockTx(ctx context.Context, tx *sql.Tx, b *explorer.Block) (id int64, err error)
-	AddTransactionTx(ctx context.Context, tx *sql.Tx, t *explorer.Transaction) error
-	AddStateTx(ctx context.Context, tx *sql.Tx, as *explorer.State) (err error)
+	LastBlockNumber(ctx context.Context, channelName string) (
+		number int64, found bool, err error)
+	RawBlocks(ctx context.Context, channelName string, fromNumber int64,
+		limit int) ([]*explorer.RawBlock, error)
+	ResetChannel(ctx context.Context, channelName string) error
+
+	// Begin begins unit of work. Caller owns it and must end it with Commit
+	// or Rollback.
+	Begin(ctx context.Context) (StorageTx, error)
+}
+
+// StorageTx is a unit of work of storage. Writes are visible after Commit
+// only. Methods do not end unit of work on error, caller rolls it back.
+type StorageTx interface {
+	// WriteBlocks stores decoded blocks in the given order and moves
+	// checkpoints of their channels to the last of them.
+	WriteBlocks(ctx context.Context, blocks []*DecodedBlock) error
+
+	// WriteFailedBlock stores failed block of the channel with its raw block,
+	// if raw block is not nil, and moves channel checkpoint past it.
+	WriteFailedBlock(ctx context.Context, channelName string,
+		fb *explorer.FailedBlock, rb *explorer.RawBlock) error
+
+	DeleteFailedBlock(ctx context.Context, channelName string,
+		number int64) error
+
+	Commit() error
+	Rollback() error
 }
@@ -0,0 +1,339 @@
+// Package storagetest contains test cases shared by implementations of
+// hf.Storage, so memory, Postgres and SQLite storages are checked with the
+// same blocks and expectations.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"explorer"
+	"explorer/hf"
+)
+
+// Chaincode is a chaincode of states written by test blocks.
+const Chaincode = "basic"
+
+// State is an actual or old state of a key as stored by storage.
+type State struct {
+	Value              []byte `db:"raw_value"`
+	Deleted            bool   `db:"deleted"`
+	VersionBlockNumber int64  `db:"version_block_number"`
+	VersionTxNumber    int64  `db:"version_tx_number"`
+}
+
+// StatesFunc returns actual state of key of the chaincode in the channel, nil
+// if key has no actual state, and old states of key in order they were
+// added.
+type StatesFunc func(t *testing.T, channelName, key string) (*State, []State)
+
+// Write returns write of value to key by transaction txNumber of block
+// blockNumber.
+func Write(blockNumber, txNumber int64, key, value string) hf.StateWrite {
+	return hf.StateWrite{
+		State: &explorer.State{
+			Chaincode:          Chaincode,
+			Key:                key,
+			TransactionId:      transactionID(blockNumber, txNumber),
+			Type:               "string",
+			RawValue:           []byte(value),
+			VersionBlockNumber: blockNumber,
+			VersionTxNumber:    txNumber,
+		},
+	}
+}
+
+// Delete returns deletion of key by transaction txNumber of block
+// blockNumber.
+func Delete(blockNumber, txNumber int64, key string) hf.StateWrite {
+	sw := Write(blockNumber, txNumber, key, "")
+	sw.IsDelete = true
+	return sw
+}
+
+func transactionID(blockNumber, txNumber int64) string {
+	return fmt.Sprintf("tx%d-%d", blockNumber, txNumber)
+}
+
+// Block returns decoded block of the channel with the writes and valid
+// transactions of them. Raw block is set, so block is also stored as raw
+// block.
+func Block(channelName string, number int64,
+	writes ...hf.StateWrite) *hf.DecodedBlock {
+
+	b := &hf.DecodedBlock{
+		ChannelName: channelName,
+		SourceURL:   "memory",
+		Block: &explorer.Block{
+			Number: number,
+			Hash:   fmt.Sprintf("hash%d", number),
+		},
+		RawBlock: &explorer.RawBlock{
+			Number:      number,
+			Compression: "none",
+			SourceUrl:   "memory",
+			Data:        []byte(fmt.Sprintf("raw block %d", number)),
+		},
+		States: writes,
+	}
+
+	txNumbers := map[int64]bool{}
+
+	for _, w := range writes {
+		n := w.State.VersionTxNumber
+		if txNumbers[n] {
+			continue
+		}
+		txNumbers[n] = true
+
+		b.Transactions = append(b.Transactions, &explorer.Transaction{
+			Id:             transactionID(number, n),
+			Number:         int32(n),
+			ValidationCode: "VALID",
+			Type:           "ENDORSER_TRANSACTION",
+		})
+		b.Creators = append(b.Creators, nil)
+	}
+
+	b.Block.TransactionCount = int64(len(b.Transactions))
+
+	return b
+}
+
+// WriteBlocks writes blocks to storage in one unit of work.
+func WriteBlocks(t *testing.T, s hf.Storage, blocks ...*hf.DecodedBlock) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.WriteBlocks(ctx, blocks)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// CheckStates checks actual state of key, nil if key must have no actual
+// state, and its old states.
+func CheckStates(t *testing.T, states StatesFunc, channelName, key string,
+	actual *State, old ...State) {
+
+	t.Helper()
+
+	gotActual, gotOld := states(t, channelName, key)
+
+	switch {
+	case actual == nil && gotActual != nil:
+		t.Errorf("got state of `%s` %+v, want none", key, *gotActual)
+	case actual != nil && gotActual == nil:
+		t.Errorf("got no state of `%s`, want %+v", key, *actual)
+	case actual != nil && !equalStates(*gotActual, *actual):
+		t.Errorf("got state of `%s` %+v, want %+v", key, *gotActual,
+			*actual)
+	}
+
+	if len(gotOld) != len(old) {
+		t.Errorf("got old states of `%s` %+v, want %+v", key, gotOld, old)
+		return
+	}
+
+	for i := range old {
+		if !equalStates(gotOld[i], old[i]) {
+			t.Errorf("got old state %d of `%s` %+v, want %+v", i, key,
+				gotOld[i], old[i])
+		}
+	}
+}
+
+func equalStates(a, b State) bool {
+	return bytes.Equal(a.Value, b.Value) && a.Deleted == b.Deleted &&
+		a.VersionBlockNumber == b.VersionBlockNumber &&
+		a.VersionTxNumber == b.VersionTxNumber
+}
+
+// value returns stored state of write.
+func value(sw hf.StateWrite) *State {
+	return &State{
+		Value:              sw.State.RawValue,
+		Deleted:            sw.IsDelete,
+		VersionBlockNumber: sw.State.VersionBlockNumber,
+		VersionTxNumber:    sw.State.VersionTxNumber,
+	}
+}
+
+// TestRetriedBlockStates checks that writes of failed block retried after
+// later blocks don't replace later states and are added to old states.
+func TestRetriedBlockStates(t *testing.T, s hf.Storage, channelName string,
+	states StatesFunc) {
+
+	var (
+		a1 = Write(1, 0, "a", "a1")
+		d1 = Write(1, 0, "d", "d1")
+		a3 = Write(3, 0, "a", "a3")
+		d3 = Delete(3, 1, "d")
+		a2 = Write(2, 0, "a", "a2")
+		d2 = Write(2, 0, "d", "d2")
+		n2 = Write(2, 1, "n", "n2")
+	)
+
+	WriteBlocks(t, s, Block(channelName, 1, a1, d1))
+
+	// Block 2 failed, block 3 is stored before block 2 is retried.
+	WriteBlocks(t, s, Block(channelName, 3, a3, d3))
+	WriteBlocks(t, s, Block(channelName, 2, a2, d2, n2))
+
+	CheckStates(t, states, channelName, "a", value(a3),
+		*value(a1), *value(a2))
+	CheckStates(t, states, channelName, "d", nil,
+		*value(d1), *value(d3), *value(d2))
+	CheckStates(t, states, channelName, "n", value(n2))
+}
+
+// TestRawBlocks checks that raw blocks of stored and failed blocks are
+// returned in number order from the given number.
+func TestRawBlocks(t *testing.T, s hf.Storage, channelName string) {
+	ctx := context.Background()
+
+	WriteBlocks(t, s, Block(channelName, 0), Block(channelName, 1))
+
+	failed := Block(channelName, 2).RawBlock
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.WriteFailedBlock(ctx, channelName, &explorer.FailedBlock{
+		Number:      failed.Number,
+		Compression: failed.Compression,
+		SourceUrl:   failed.SourceUrl,
+		Data:        failed.Data,
+		Error:       "failed",
+		Failures:    1,
+	}, failed)
+	if err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	WriteBlocks(t, s, Block(channelName, 3), Block(channelName, 4))
+
+	for _, c := range []struct {
+		from    int64
+		limit   int
+		numbers []int64
+	}{
+		{0, 10, []int64{0, 1, 2, 3, 4}},
+		{1, 2, []int64{1, 2}},
+		{4, 10, []int64{4}},
+		{5, 10, nil},
+	} {
+		rbs, err := s.RawBlocks(ctx, channelName, c.from, c.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rbs) != len(c.numbers) {
+			t.Errorf("got %d raw blocks from %d limit %d, want %v",
+				len(rbs), c.from, c.limit, c.numbers)
+			continue
+		}
+
+		for i, rb := range rbs {
+			want := Block(channelName, c.numbers[i]).RawBlock
+			if rb.Number != want.Number ||
+				rb.Compression != want.Compression ||
+				rb.SourceUrl != want.SourceUrl ||
+				!bytes.Equal(rb.Data, want.Data) {
+				t.Errorf("got raw block %d %v, want %v", i, rb, want)
+			}
+		}
+	}
+
+	rbs, err := s.RawBlocks(ctx, "unknown"+channelName, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rbs) != 0 {
+		t.Errorf("got %d raw blocks of unknown channel", len(rbs))
+	}
+}
+
+// TestResetChannel checks that reset removes indexed data of the channel
+// except raw blocks, and the channel is indexed again from the first block.
+func TestResetChannel(t *testing.T, s hf.Storage, channelName string,
+	states StatesFunc) {
+
+	ctx := context.Background()
+
+	a0 := Write(0, 0, "a", "a0")
+	a1 := Write(1, 0, "a", "a1")
+	d1 := Delete(1, 1, "d")
+
+	WriteBlocks(t, s, Block(channelName, 0, a0, Write(0, 1, "d", "d0")))
+	WriteBlocks(t, s, Block(channelName, 1, a1, d1))
+
+	err := s.ResetChannel(ctx, channelName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found, err := s.LastBlockNumber(ctx, channelName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Error("got last block number of reset channel")
+	}
+
+	CheckStates(t, states, channelName, "a", nil)
+	CheckStates(t, states, channelName, "d", nil)
+
+	rbs, err := s.RawBlocks(ctx, channelName, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rbs) != 2 {
+		t.Errorf("got %d raw blocks of reset channel, want 2", len(rbs))
+	}
+
+	// Channel is reindexed from its first block, reindexed blocks are not
+	// stored as raw blocks again.
+	b := Block(channelName, 0, a0)
+	b.RawBlock = nil
+
+	WriteBlocks(t, s, b)
+
+	n, found, err := s.LastBlockNumber(ctx, channelName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || n != 0 {
+		t.Errorf("got last block number %d (found %t), want 0", n, found)
+	}
+
+	CheckStates(t, states, channelName, "a", value(a0))
+
+	// Reset of unknown channel does nothing.
+	err = s.ResetChannel(ctx, "unknown"+channelName)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,384 @@
+package hf
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	fabricPeer "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/sirupsen/logrus"
+
+	"explorer"
+)
+
+// StateWrite is a key write made by a transaction: either a new key value or
+// a key deletion.
+type StateWrite struct {
+	State    *explorer.State
+	IsDelete bool
+}
+
+// Precedes reports whether write is older than the given version of key, e.g.
+// write of failed block retried after later blocks are stored.
+func (sw StateWrite) Precedes(blockNumber, txNumber int64) bool {
+	return sw.State.VersionBlockNumber < blockNumber ||
+		sw.State.VersionBlockNumber == blockNumber &&
+			sw.State.VersionTxNumber < txNumber
+}
+
+// RangeQuery is a range query made by a transaction with keys it read.
+type RangeQuery struct {
+	RangeQuery *explorer.RangeQuery
+	Reads      []*explorer.Read
+}
+
+// endorserTransaction is a decoded endorser transaction.
+type endorserTransaction struct {
+	chaincode    *explorer.Chaincode
+	reads        []*explorer.Read
+	rangeQueries []RangeQuery
+	states       []StateWrite
+
+	privateWriteHashes []*explorer.PrivateWriteHash
+	chaincodeEvents    []*explorer.ChaincodeEvent
+	endorsements       []*explorer.Endorsement
+
+	function  string
+	arguments []*explorer.Argument
+}
+
+func decodeEndorserTransaction(log *logrus.Entry,
+	transaction *explorer.Transaction, channelHeader *common.ChannelHeader,
+	payload *common.Payload) (*endorserTransaction, error) {
+
+	et := &endorserTransaction{}
+
+	channelHeaderExtension := &fabricPeer.ChaincodeHeaderExtension{}
+
+	err := proto.Unmarshal(channelHeader.Extension, channelHeaderExtension)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"unmarshal channel header extension: %w", err)
+	}
+
+	et.chaincode = &explorer.Chaincode{
+		Name:    channelHeaderExtension.GetChaincodeId().GetName(),
+		Version: channelHeaderExtension.GetChaincodeId().GetVersion(),
+	}
+
+	fabricTransaction := &fabricPeer.Transaction{}
+
+	err = proto.Unmarshal(payload.Data, fabricTransaction)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal transaction: %w", err)
+	}
+
+	for _, a := range fabricTransaction.Actions {
+		chaincodeActionPayload := &fabricPeer.ChaincodeActionPayload{}
+
+		err = proto.Unmarshal(a.Payload, chaincodeActionPayload)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"unmarshal chaincode action payload: %w", err)
+		}
+
+		for _, e := range chaincodeActionPayload.GetAction().GetEndorsements() {
+			endorser, err := decodeIdentity(e.Endorser)
+			if err != nil {
+				return nil, fmt.Errorf("decode endorser identity: %w", err)
+			}
+			et.endorsements = append(et.endorsements, &explorer.Endorsement{
+				TransactionId:     transaction.Id,
+				TransactionNumber: transaction.Number,
+				MspId:             endorser.MspId,
+				Subject:           endorser.Subject,
+				Fingerprint:       endorser.Fingerprint,
+			})
+		}
+
+		if et.function == "" {
+			et.function, et.arguments, err = decodeInvocation(log,
+				transaction, chaincodeActionPayload.ChaincodeProposalPayload)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"decode chaincode invocation: %w", err)
+			}
+		}
+
+		proposalResponsePayload := &fabricPeer.ProposalResponsePayload{}
+
+		err = proto.Unmarshal(
+			chaincodeActionPayload.GetAction().GetProposalResponsePayload(),
+			proposalResponsePayload)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"unmarshal proposal response payload: %w", err)
+		}
+
+		chaincodeAction := &fabricPeer.ChaincodeAction{}
+		err = proto.Unmarshal(
+			proposalResponsePayload.Extension,
+			chaincodeAction)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"unmarshal chaincode action: %w", err)
+		}
+
+		if len(chaincodeAction.Events) > 0 {
+			ce, err := decodeChaincodeEvent(log, transaction,
+				chaincodeAction.Events)
+			if err != nil {
+				return nil, fmt.Errorf("decode chaincode event: %w", err)
+			}
+			et.chaincodeEvents = append(et.chaincodeEvents, ce)
+		}
+
+		txReadWriteSet := &rwset.TxReadWriteSet{}
+		err = proto.Unmarshal(
+			chaincodeAction.Results,
+			txReadWriteSet)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"unmarshal transaction read write set: %w", err)
+		}
+
+		for _, rw := range txReadWriteSet.NsRwset {
+			kvRWSet := &kvrwset.KVRWSet{}
+			err = proto.Unmarshal(rw.Rwset, kvRWSet)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"unmarshal kv rw set: %w", err)
+			}
+
+			for _, r := range kvRWSet.Reads {
+				et.reads = append(et.reads,
+					newRead(transaction, rw.Namespace, r))
+			}
+
+			for _, rqi := range kvRWSet.RangeQueriesInfo {
+				rq := RangeQuery{
+					RangeQuery: &explorer.RangeQuery{
+						TransactionId:     transaction.Id,
+						TransactionNumber: transaction.Number,
+						Chaincode:         rw.Namespace,
+						StartKey:          rqi.StartKey,
+						EndKey:            rqi.EndKey,
+						ItrExhausted:      rqi.ItrExhausted,
+					},
+				}
+				// Range query results may be given as merkle tree hashes
+				// instead of raw reads, they are not stored.
+				for _, r := range rqi.GetRawReads().GetKvReads() {
+					rq.Reads = append(rq.Reads,
+						newRead(transaction, rw.Namespace, r))
+				}
+				et.rangeQueries = append(et.rangeQueries, rq)
+			}
+
+			for _, ch := range rw.CollectionHashedRwset {
+				hashedRWSet := &kvrwset.HashedRWSet{}
+				err = proto.Unmarshal(ch.HashedRwset, hashedRWSet)
+				if err != nil {
+					return nil, fmt.Errorf(
+						"unmarshal hashed rw set: %w", err)
+				}
+
+				for _, w := range hashedRWSet.HashedWrites {
+					et.privateWriteHashes = append(et.privateWriteHashes,
+						&explorer.PrivateWriteHash{
+							TransactionId: transaction.Id,
+							Chaincode:     rw.Namespace,
+							Collection:    ch.CollectionName,
+							KeyHash:       w.KeyHash,
+							ValueHash:     w.ValueHash,
+							IsDelete:      w.IsDelete,
+							CreatedAt:     transaction.CreatedAt,
+						})
+				}
+			}
+
+			for _, w := range kvRWSet.Writes {
+				if w.IsDelete {
+					et.states = append(et.states, StateWrite{
+						State: &explorer.State{
+							Key:           w.Key,
+							Chaincode:     rw.Namespace,
+							CreatedAt:     transaction.CreatedAt,
+							TransactionId: transaction.Id,
+						},
+						IsDelete: true,
+					})
+					continue
+				}
+
+				stateType, parsedValue, err := parseValue(rw.Namespace, w.Key, w.Value)
+				if err != nil {
+					log.WithError(err).WithField("key", w.Key).
+						Warning("failed to parse state value")
+				}
+
+				et.states = append(et.states, StateWrite{
+					State: &explorer.State{
+						Key:           w.Key,
+						Chaincode:     rw.Namespace,
+						CreatedAt:     transaction.CreatedAt,
+						Type:          stateType,
+						TransactionId: transaction.Id,
+						RawValue:      w.Value,
+						Value:         parsedValue,
+					},
+				})
+			}
+		}
+	}
+
+	return et, nil
+}
+
+// decodeInvocation decodes invoked chaincode function name and its arguments.
+// Arguments are parsed using argument types registered for invoked chaincode
+// function.
+func decodeInvocation(log *logrus.Entry, transaction *explorer.Transaction,
+	rawProposalPayload []byte) (string, []*explorer.Argument, error) {
+
+	chaincodeProposalPayload := &fabricPeer.ChaincodeProposalPayload{}
+
+	err := proto.Unmarshal(rawProposalPayload, chaincodeProposalPayload)
+	if err != nil {
+		return "", nil, fmt.Errorf(
+			"unmarshal chaincode proposal payload: %w", err)
+	}
+
+	chaincodeInvocationSpec := &fabricPeer.ChaincodeInvocationSpec{}
+
+	err = proto.Unmarshal(chaincodeProposalPayload.Input,
+		chaincodeInvocationSpec)
+	if err != nil {
+		return "", nil, fmt.Errorf(
+			"unmarshal chaincode invocation spec: %w", err)
+	}
+
+	spec := chaincodeInvocationSpec.ChaincodeSpec
+	if spec == nil || spec.Input == nil || len(spec.Input.Args) == 0 {
+		return "", nil, nil
+	}
+
+	chaincodeName := spec.GetChaincodeId().GetName()
+	function := string(spec.Input.Args[0])
+
+	var args []*explorer.Argument
+
+	for i, rawArg := range spec.Input.Args[1:] {
+		arg := &explorer.Argument{
+			TransactionId:     transaction.Id,
+			TransactionNumber: transaction.Number,
+			Index:             int32(i),
+			RawValue:          rawArg,
+		}
+
+		arg.Type, arg.Value, err = parseArgument(chaincodeName, function, i,
+			rawArg)
+		if err != nil {
+			log.WithError(err).WithFields(logrus.Fields{
+				"function": function,
+				"index":    i,
+			}).Warning("failed to parse argument")
+		}
+
+		args = append(args, arg)
+	}
+
+	return function, args, nil
+}
+
+// decodeChaincodeEvent decodes chaincode event and parses its payload using
+// value types registered for event chaincode with event name as a key.
+func decodeChaincodeEvent(log *logrus.Entry, transaction *explorer.Transaction,
+	rawEvent []byte) (*explorer.ChaincodeEvent, error) {
+
+	chaincodeEvent := &fabricPeer.ChaincodeEvent{}
+
+	err := proto.Unmarshal(rawEvent, chaincodeEvent)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal chaincode event: %w", err)
+	}
+
+	ce := &explorer.ChaincodeEvent{
+		TransactionId: transaction.Id,
+		Chaincode:     chaincodeEvent.ChaincodeId,
+		Name:          chaincodeEvent.EventName,
+		RawPayload:    chaincodeEvent.Payload,
+		CreatedAt:     transaction.CreatedAt,
+	}
+
+	ce.Type, ce.Payload, err = parseValue(ce.Chaincode, ce.Name,
+		chaincodeEvent.Payload)
+	if err != nil {
+		log.WithError(err).WithField("event_name", ce.Name).
+			Warning("failed to parse chaincode event payload")
+	}
+
+	return ce, nil
+}
+
+func decodePrivateWrites(log *logrus.Entry,
+	transaction *explorer.Transaction, pvtRWSet *rwset.TxPvtReadWriteSet) (
+	[]*explorer.PrivateWrite, error) {
+
+	var pws []*explorer.PrivateWrite
+
+	for _, ns := range pvtRWSet.NsPvtRwset {
+		for _, c := range ns.CollectionPvtRwset {
+			kvRWSet := &kvrwset.KVRWSet{}
+			err := proto.Unmarshal(c.Rwset, kvRWSet)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"unmarshal private kv rw set: %w", err)
+			}
+
+			for _, w := range kvRWSet.Writes {
+				pw := &explorer.PrivateWrite{
+					TransactionId: transaction.Id,
+					Chaincode:     ns.Namespace,
+					Collection:    c.CollectionName,
+					Key:           w.Key,
+					IsDelete:      w.IsDelete,
+					CreatedAt:     transaction.CreatedAt,
+				}
+
+				if !w.IsDelete {
+					pw.RawValue = w.Value
+					pw.Type, pw.Value, err = parseValue(ns.Namespace,
+						w.Key, w.Value)
+					if err != nil {
+						log.WithError(err).WithField("key", w.Key).
+							Warning("failed to parse private value")
+					}
+				}
+
+				pws = append(pws, pw)
+			}
+		}
+	}
+
+	return pws, nil
+}
+
+func newRead(transaction *explorer.Transaction, chaincode string,
+	r *kvrwset.KVRead) *explorer.Read {
+
+	read := &explorer.Read{
+		TransactionId:     transaction.Id,
+		TransactionNumber: transaction.Number,
+		Chaincode:         chaincode,
+		Key:               r.Key,
+	}
+	if r.Version != nil {
+		read.HasVersion = true
+		read.VersionBlockNumber = int64(r.Version.BlockNum)
+		read.VersionTxNumber = int64(r.Version.TxNum)
+	}
+	return read
+}
@@ -2,15 +2,19 @@ package hf
 
 import (
 	"encoding/json"
-	"reflect"
 	"regexp"
 
+	protoV1 "github.com/golang/protobuf/proto"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
+// protobufvalueType is a protobuf value type given by generated message type
+// or, for types loaded in runtime, by dynamic message type.
 type protobufvalueType struct {
 	StateType string
-	Format    reflect.Type
+	Format    protoreflect.MessageType
 }
 
 var protobufValueTypes = map[string]map[*regexp.Regexp]protobufvalueType{}
@@ -18,19 +22,48 @@ var protobufValueTypes = map[string]map[*regexp.Regexp]protobufvalueType{}
 func RegisterProtobufValueType(chaincodeName string, keyMatchRegexp string,
 	stateType string, format proto.Message) {
 
+	registerProtobufValueType(chaincodeName, keyMatchRegexp,
+		protobufvalueType{
+			StateType: stateType,
+			Format:    format.ProtoReflect().Type(),
+		})
+}
+
+// RegisterDynamicProtobufValueType registers protobuf value type given by
+// message descriptor, values are decoded with dynamic messages.
+func RegisterDynamicProtobufValueType(chaincodeName string,
+	keyMatchRegexp string, stateType string,
+	format protoreflect.MessageDescriptor) {
+
+	registerProtobufValueType(chaincodeName, keyMatchRegexp,
+		protobufvalueType{
+			StateType: stateType,
+			Format:    dynamicpb.NewMessageType(format),
+		})
+}
+
+// registerProtobufValueType registers value type, it replaces value type
+// registered before with the same regexp.
+func registerProtobufValueType(chaincodeName string, keyMatchRegexp string,
+	vt protobufvalueType) {
+
 	if protobufValueTypes[chaincodeName] == nil {
 		protobufValueTypes[chaincodeName] = map[*regexp.Regexp]protobufvalueType{}
 	}
 
-	protobufValueTypes[chaincodeName][regexp.MustCompile(keyMatchRegexp)] =
-		protobufvalueType{
-			StateType: stateType,
-			Format:    reflect.TypeOf(format),
+	for m := range protobufValueTypes[chaincodeName] {
+		if m.String() == keyMatchRegexp {
+			delete(protobufValueTypes[chaincodeName], m)
 		}
+	}
+
+	protobufValueTypes[chaincodeName][regexp.MustCompile(keyMatchRegexp)] = vt
 }
 
+// unmarshalProtobufValue returns state type and value of the first matching
+// value type as protobuf JSON, nil value if no value type matches.
 func unmarshalProtobufValue(chaincodeName, key string, rawValue []byte) (
-	string, interface{}, error) {
+	string, json.RawMessage, error) {
 
 	ccValueTypes, exists := protobufValueTypes[chaincodeName]
 	if !exists {
@@ -38,30 +71,36 @@ func unmarshalProtobufValue(chaincodeName, key string, rawValue []byte) (
 	}
 
 	var (
-		stateType string
-		format    reflect.Type
+		vt    protobufvalueType
+		found bool
 	)
 
 	for m, f := range ccValueTypes {
 		if m.MatchString(key) {
-			stateType = f.StateType
-			format = f.Format
+			vt = f
+			found = true
 			break
 		}
 	}
 
-	if format == nil {
+	if !found {
 		return "", nil, nil
 	}
 
-	value := reflect.New(format)
+	value := vt.Format.New().Interface()
 
-	err := proto.Unmarshal(rawValue, value.Interface().(proto.Message))
+	err := proto.Unmarshal(rawValue, value)
 	if err != nil {
 		return "", nil, err
 	}
 
-	return stateType, value.Interface(), nil
+	// Values are encoded like protobuf messages of chaincode definitions.
+	valueJSON, err := marshalProtoJSON(protoV1.MessageV1(value))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return vt.StateType, valueJSON, nil
 }
 
 type jsonValueType struct {
@@ -74,11 +113,20 @@ var jsonValueTypes = map[string][]jsonValueType{}
 func RegisterJSONValueType(chaincodeName string, keyMatchRegexp string,
 	stateType string) {
 
-	jsonValueTypes[chaincodeName] = append(jsonValueTypes[chaincodeName],
-		jsonValueType{
-			regexp:    regexp.MustCompile(keyMatchRegexp),
-			stateType: stateType,
-		})
+	vt := jsonValueType{
+		regexp:    regexp.MustCompile(keyMatchRegexp),
+		stateType: stateType,
+	}
+
+	// Value type registered before with the same regexp is replaced.
+	for i, registered := range jsonValueTypes[chaincodeName] {
+		if registered.regexp.String() == keyMatchRegexp {
+			jsonValueTypes[chaincodeName][i] = vt
+			return
+		}
+	}
+
+	jsonValueTypes[chaincodeName] = append(jsonValueTypes[chaincodeName], vt)
 }
 
 func isJSONValue(chaincodeName, key string) (string, bool) {
@@ -104,14 +152,14 @@ func parseValue(chaincodeName, key string, rawValue []byte) (string, json.RawMes
 		return stateType, rawValue, nil
 	}
 
-	stateType, v, err := unmarshalProtobufValue(chaincodeName, key, rawValue)
+	stateType, vJSON, err := unmarshalProtobufValue(chaincodeName, key,
+		rawValue)
 	if err != nil {
 		return "", nil, err
 	}
 
-	vJSON, err := json.Marshal(v)
-	if err != nil {
-		return "", nil, err
+	if vJSON == nil {
+		return stateType, json.RawMessage("null"), nil
 	}
 
 	return stateType, vJSON, nil
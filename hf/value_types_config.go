@@ -0,0 +1,107 @@
+package hf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	ValueFormatJSON     = "json"
+	ValueFormatProtobuf = "protobuf"
+)
+
+// ValueTypeConfig is a type of chaincode state values with keys matching
+// KeyRegexp. Format is "json" or "protobuf". Protobuf values are decoded as
+// Message of compiled FileDescriptorSet from DescriptorSetFile, which must
+// include imports of its files, for example made by
+// `protoc --include_imports --descriptor_set_out`.
+type ValueTypeConfig struct {
+	Chaincode         string `yaml:"chaincode"`
+	KeyRegexp         string `yaml:"key_regexp"`
+	StateType         string `yaml:"state_type"`
+	Format            string `yaml:"format"`
+	DescriptorSetFile string `yaml:"descriptor_set_file"`
+	Message           string `yaml:"message"`
+}
+
+// RegisterValueTypes registers value types of configs.
+func RegisterValueTypes(configs []ValueTypeConfig) error {
+
+	descriptorSets := map[string]*protoregistry.Files{}
+
+	for _, c := range configs {
+		_, err := regexp.Compile(c.KeyRegexp)
+		if err != nil {
+			return fmt.Errorf("compile key regexp of `%s` value type: %w",
+				c.StateType, err)
+		}
+
+		switch c.Format {
+		case ValueFormatJSON:
+			RegisterJSONValueType(c.Chaincode, c.KeyRegexp, c.StateType)
+
+		case ValueFormatProtobuf:
+			files, ok := descriptorSets[c.DescriptorSetFile]
+			if !ok {
+				files, err = loadDescriptorSet(c.DescriptorSetFile)
+				if err != nil {
+					return fmt.Errorf(
+						"load descriptor set of `%s` value type: %w",
+						c.StateType, err)
+				}
+				descriptorSets[c.DescriptorSetFile] = files
+			}
+
+			d, err := files.FindDescriptorByName(
+				protoreflect.FullName(c.Message))
+			if err != nil {
+				return fmt.Errorf("find message `%s` of `%s` value type: %w",
+					c.Message, c.StateType, err)
+			}
+
+			md, ok := d.(protoreflect.MessageDescriptor)
+			if !ok {
+				return fmt.Errorf("`%s` of `%s` value type is not a message",
+					c.Message, c.StateType)
+			}
+
+			RegisterDynamicProtobufValueType(c.Chaincode, c.KeyRegexp,
+				c.StateType, md)
+
+		default:
+			return fmt.Errorf("unknown format `%s` of `%s` value type",
+				c.Format, c.StateType)
+		}
+	}
+
+	return nil
+}
+
+func loadDescriptorSet(path string) (*protoregistry.Files, error) {
+
+	rawSet, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+
+	err = proto.Unmarshal(rawSet, set)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal file descriptor set: %w", err)
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("create file descriptors: %w", err)
+	}
+
+	return files, nil
+}
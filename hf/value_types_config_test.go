@@ -0,0 +1,277 @@
+package hf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// descriptorSetFixture is a FileDescriptorSet of valueTypesFileDescriptor,
+// it is written by test with -update flag.
+var descriptorSetFixture = filepath.Join("testdata", "value_types.pb")
+
+// valueTypesFileDescriptor returns descriptor of file
+//
+//	syntax = "proto3";
+//	package explorer.test;
+//	message Asset {
+//	  string id = 1;
+//	  string owner = 2;
+//	  int64 appraised_value = 3;
+//	}
+//	enum Color { RED = 0; }
+func valueTypesFileDescriptor() *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32,
+		t descriptorpb.FieldDescriptorProto_Type,
+		jsonName string) *descriptorpb.FieldDescriptorProto {
+
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     t.Enum(),
+			JsonName: proto.String(jsonName),
+		}
+	}
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("explorer/test/asset.proto"),
+		Package: proto.String("explorer.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Asset"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING,
+					"id"),
+				field("owner", 2,
+					descriptorpb.FieldDescriptorProto_TYPE_STRING, "owner"),
+				field("appraised_value", 3,
+					descriptorpb.FieldDescriptorProto_TYPE_INT64,
+					"appraisedValue"),
+			},
+		}},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Color"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{{
+				Name:   proto.String("RED"),
+				Number: proto.Int32(0),
+			}},
+		}},
+	}
+}
+
+func writeDescriptorSetFixture(t *testing.T) {
+	t.Helper()
+
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{valueTypesFileDescriptor()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(descriptorSetFixture, set, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resetValueTypes restores registered value types after test.
+func resetValueTypes(t *testing.T) {
+	t.Helper()
+
+	jsonTypes := jsonValueTypes
+	protobufTypes := protobufValueTypes
+
+	jsonValueTypes = map[string][]jsonValueType{}
+	protobufValueTypes = map[string]map[*regexp.Regexp]protobufvalueType{}
+
+	t.Cleanup(func() {
+		jsonValueTypes = jsonTypes
+		protobufValueTypes = protobufTypes
+	})
+}
+
+// checkValue checks that value of key is parsed with the state type to
+// JSON equal to the expected one.
+func checkValue(t *testing.T, chaincodeName, key string, rawValue []byte,
+	stateType string, expected string) {
+
+	t.Helper()
+
+	gotType, gotJSON, err := parseValue(chaincodeName, key, rawValue)
+	if err != nil {
+		t.Errorf("parse value of `%s`: %v", key, err)
+		return
+	}
+
+	if gotType != stateType {
+		t.Errorf("got state type `%s` of `%s`, want `%s`", gotType, key,
+			stateType)
+	}
+
+	// Protobuf JSON encoder randomly adds spaces, so values are compared
+	// decoded.
+	var got, want interface{}
+
+	err = json.Unmarshal(gotJSON, &got)
+	if err != nil {
+		t.Errorf("unmarshal value of `%s` %s: %v", key, gotJSON, err)
+		return
+	}
+
+	err = json.Unmarshal([]byte(expected), &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got value of `%s` %s, want %s", key, gotJSON, expected)
+	}
+}
+
+func TestRegisterValueTypes(t *testing.T) {
+	if *updateFixtures {
+		writeDescriptorSetFixture(t)
+	}
+
+	resetValueTypes(t)
+
+	configs := []ValueTypeConfig{{
+		Chaincode: "basic",
+		KeyRegexp: "^json_",
+		StateType: "json_asset",
+		Format:    ValueFormatJSON,
+	}, {
+		Chaincode:         "basic",
+		KeyRegexp:         "^protobuf_",
+		StateType:         "protobuf_asset",
+		Format:            ValueFormatProtobuf,
+		DescriptorSetFile: descriptorSetFixture,
+		Message:           "explorer.test.Asset",
+	}}
+
+	// Value types are registered again, for example by explorer restarted
+	// in the same process, they must not be duplicated.
+	for i := 0; i < 2; i++ {
+		err := RegisterValueTypes(configs)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(jsonValueTypes["basic"]); n != 1 {
+		t.Errorf("got %d JSON value types, want 1", n)
+	}
+
+	if n := len(protobufValueTypes["basic"]); n != 1 {
+		t.Errorf("got %d protobuf value types, want 1", n)
+	}
+
+	checkValue(t, "basic", "json_1", []byte(`{"id":"json_1"}`),
+		"json_asset", `{"id":"json_1"}`)
+
+	files, err := loadDescriptorSet(descriptorSetFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := files.FindDescriptorByName("explorer.test.Asset")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asset := dynamicpb.NewMessage(d.(protoreflect.MessageDescriptor))
+	fields := asset.Descriptor().Fields()
+	asset.Set(fields.ByName("id"), protoreflect.ValueOfString("protobuf_1"))
+	asset.Set(fields.ByName("owner"), protoreflect.ValueOfString("Tom"))
+	asset.Set(fields.ByName("appraised_value"), protoreflect.ValueOfInt64(300))
+
+	rawAsset, err := proto.Marshal(asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkValue(t, "basic", "protobuf_1", rawAsset, "protobuf_asset",
+		`{"id":"protobuf_1","owner":"Tom","appraised_value":"300"}`)
+
+	checkValue(t, "basic", "other", []byte("other"), "", "null")
+
+	_, _, err = parseValue("basic", "protobuf_2", []byte("not protobuf"))
+	if err == nil {
+		t.Error("got no error of malformed protobuf value")
+	}
+}
+
+func TestRegisterProtobufValueType(t *testing.T) {
+	resetValueTypes(t)
+
+	RegisterProtobufValueType("basic", "^timestamp_", "timestamp",
+		&timestamppb.Timestamp{})
+
+	rawTimestamp, err := proto.Marshal(&timestamppb.Timestamp{Seconds: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compiled types are encoded as protobuf JSON like dynamic ones.
+	checkValue(t, "basic", "timestamp_1", rawTimestamp, "timestamp",
+		`"1970-01-01T00:00:01Z"`)
+}
+
+func TestRegisterValueTypesErrors(t *testing.T) {
+	resetValueTypes(t)
+
+	protobufConfig := func(message string) ValueTypeConfig {
+		return ValueTypeConfig{
+			Chaincode:         "basic",
+			KeyRegexp:         "^asset_",
+			StateType:         "asset",
+			Format:            ValueFormatProtobuf,
+			DescriptorSetFile: descriptorSetFixture,
+			Message:           message,
+		}
+	}
+
+	badRegexp := protobufConfig("explorer.test.Asset")
+	badRegexp.KeyRegexp = "^asset_("
+
+	missingFile := protobufConfig("explorer.test.Asset")
+	missingFile.DescriptorSetFile = filepath.Join("testdata", "missing.pb")
+
+	unknownFormat := protobufConfig("explorer.test.Asset")
+	unknownFormat.Format = "xml"
+
+	for _, c := range []struct {
+		name   string
+		config ValueTypeConfig
+		err    string
+	}{
+		{"bad regexp", badRegexp, "compile key regexp"},
+		{"missing descriptor set", missingFile, "load descriptor set"},
+		{"missing message", protobufConfig("explorer.test.Missing"),
+			"find message `explorer.test.Missing`"},
+		{"not a message", protobufConfig("explorer.test.Color"),
+			"is not a message"},
+		{"unknown format", unknownFormat, "unknown format `xml`"},
+	} {
+		err := RegisterValueTypes([]ValueTypeConfig{c.config})
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got error %v, want `%s`", c.name, err, c.err)
+		}
+	}
+
+	if len(jsonValueTypes) != 0 || len(protobufValueTypes) != 0 {
+		t.Error("got value types registered by failed configs")
+	}
+}
@@ -0,0 +1,942 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/lib/pq"
+
+	"explorer"
+	"explorer/hf"
+)
+
+// insertChunkSize is a max count of rows inserted by one multi-row insert.
+const insertChunkSize = 1000
+
+// storeBlocksTx stores decoded blocks with multi-row inserts and COPY, so
+// processor throughput is not limited by round trip per row.
+func storeBlocksTx(ctx context.Context, tx *sql.Tx, txx *goqu.TxDatabase,
+	blocks []*hf.DecodedBlock) error {
+
+	peerIDs, channelIDs, err := addPeersAndChannelsTx(ctx, txx, blocks)
+	if err != nil {
+		return err
+	}
+
+	var (
+		peerChannels  []goqu.Record
+		organizations []goqu.Record
+		policies      []goqu.Record
+	)
+
+	for _, b := range blocks {
+		channelID := channelIDs[b.ChannelName]
+
+		peerChannels = append(peerChannels, goqu.Record{
+			"peer_id":    peerIDs[b.SourceURL],
+			"channel_id": channelID,
+		})
+
+		for _, cc := range b.ChannelConfigs {
+			cc.Config.ChannelId = channelID
+			cc.Config.Id, err = addChannelConfigTx(ctx, txx, cc.Config)
+			if err != nil {
+				return fmt.Errorf("add channel config to DB: %w", err)
+			}
+			for _, o := range cc.Organizations {
+				o.ChannelConfigId = cc.Config.Id
+				organizations = append(organizations,
+					channelOrganizationRecord(o))
+			}
+			for _, cp := range cc.Policies {
+				cp.ChannelConfigId = cc.Config.Id
+				policies = append(policies, channelPolicyRecord(cp))
+			}
+		}
+	}
+
+	err = insertRecordsTx(ctx, txx, peerChannel, peerChannels, true)
+	if err != nil {
+		return fmt.Errorf("add peer channels to DB: %w", err)
+	}
+
+	err = insertRecordsTx(ctx, txx, channelOrganization, organizations, false)
+	if err != nil {
+		return fmt.Errorf("add channel organizations to DB: %w", err)
+	}
+
+	err = insertRecordsTx(ctx, txx, channelPolicy, policies, false)
+	if err != nil {
+		return fmt.Errorf("add channel policies to DB: %w", err)
+	}
+
+	err = addChaincodesTx(ctx, txx, blocks, channelIDs)
+	if err != nil {
+		return err
+	}
+
+	err = addBlocksTx(ctx, txx, blocks, channelIDs)
+	if err != nil {
+		return err
+	}
+
+	err = addIdentitiesTx(ctx, txx, blocks)
+	if err != nil {
+		return err
+	}
+
+	err = addTransactionsTx(ctx, tx, txx, blocks)
+	if err != nil {
+		return err
+	}
+
+	err = addStatesTx(ctx, tx, txx, blocks)
+	if err != nil {
+		return err
+	}
+
+	var approvals []goqu.Record
+
+	for _, b := range blocks {
+		for _, cdc := range b.ChaincodeDefinitions {
+			cdc.Definition.ChannelId = b.Block.ChannelId
+			cdc.Definition.Id, err = addChaincodeDefinitionTx(ctx, txx,
+				cdc.Definition)
+			if err != nil {
+				return fmt.Errorf("add chaincode definition to DB: %w", err)
+			}
+			if cdc.Approval != nil {
+				cdc.Approval.ChaincodeDefinitionId = cdc.Definition.Id
+				approvals = append(approvals,
+					chaincodeApprovalRecord(cdc.Approval))
+			}
+		}
+	}
+
+	err = insertRecordsTx(ctx, txx, chaincodeApproval, approvals, false)
+	if err != nil {
+		return fmt.Errorf("add chaincode approvals to DB: %w", err)
+	}
+
+	return nil
+}
+
+// addPeersAndChannelsTx adds peers and channels of blocks and returns their
+// IDs by peer URL and channel name.
+func addPeersAndChannelsTx(ctx context.Context, txx *goqu.TxDatabase,
+	blocks []*hf.DecodedBlock) (peerIDs, channelIDs map[string]int64,
+	err error) {
+
+	var urls, names []string
+
+	peerIDs = map[string]int64{}
+	channelIDs = map[string]int64{}
+
+	for _, b := range blocks {
+		if _, ok := peerIDs[b.SourceURL]; !ok {
+			peerIDs[b.SourceURL] = 0
+			urls = append(urls, b.SourceURL)
+		}
+		if _, ok := channelIDs[b.ChannelName]; !ok {
+			channelIDs[b.ChannelName] = 0
+			names = append(names, b.ChannelName)
+		}
+	}
+
+	err = upsertIDsTx(ctx, txx, peer, "url", urls, peerIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("add peers to DB: %w", err)
+	}
+
+	err = upsertIDsTx(ctx, txx, channel, "name", names, channelIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("add channels to DB: %w", err)
+	}
+
+	return peerIDs, channelIDs, nil
+}
+
+// upsertIDsTx adds rows with the given unique column values if they are not
+// exist yet and sets IDs of all rows to ids by column value.
+func upsertIDsTx(ctx context.Context, txx *goqu.TxDatabase, table, column string,
+	values []string, ids map[string]int64) error {
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	records := make([]goqu.Record, 0, len(values))
+	for _, v := range values {
+		records = append(records, goqu.Record{column: v})
+	}
+
+	err := insertRecordsTx(ctx, txx, table, records, true)
+	if err != nil {
+		return err
+	}
+
+	rows, err := txx.From(table).
+		Select("id", column).
+		Where(goqu.Ex{column: values}).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id int64
+			v  string
+		)
+		err = rows.Scan(&id, &v)
+		if err != nil {
+			return err
+		}
+		ids[v] = id
+	}
+
+	return rows.Err()
+}
+
+func addChaincodesTx(ctx context.Context, txx *goqu.TxDatabase,
+	blocks []*hf.DecodedBlock, channelIDs map[string]int64) error {
+
+	type chaincodeKey struct{ name, version string }
+
+	var (
+		keys       []chaincodeKey
+		ids        = map[chaincodeKey]int64{}
+		chaincodes []goqu.Record
+		where      []exp.Expression
+	)
+
+	for _, b := range blocks {
+		for _, c := range b.Chaincodes {
+			k := chaincodeKey{name: c.Name, version: c.Version}
+			if _, ok := ids[k]; ok {
+				continue
+			}
+			ids[k] = 0
+			keys = append(keys, k)
+			chaincodes = append(chaincodes, goqu.Record{
+				"name":    c.Name,
+				"version": c.Version,
+			})
+			where = append(where, goqu.Ex{
+				"name":    c.Name,
+				"version": c.Version,
+			})
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := insertRecordsTx(ctx, txx, chaincode, chaincodes, true)
+	if err != nil {
+		return fmt.Errorf("add chaincodes to DB: %w", err)
+	}
+
+	rows, err := txx.From(chaincode).
+		Select("id", "name", "version").
+		Where(goqu.Or(where...)).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return fmt.Errorf("get chaincodes from DB: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id int64
+			k  chaincodeKey
+		)
+		err = rows.Scan(&id, &k.name, &k.version)
+		if err != nil {
+			return fmt.Errorf("scan chaincode: %w", err)
+		}
+		ids[k] = id
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("get chaincodes from DB: %w", err)
+	}
+
+	var channelChaincodes []goqu.Record
+
+	for _, b := range blocks {
+		for _, c := range b.Chaincodes {
+			c.Id = ids[chaincodeKey{name: c.Name, version: c.Version}]
+			channelChaincodes = append(channelChaincodes, goqu.Record{
+				"channel_id":   channelIDs[b.ChannelName],
+				"chaincode_id": c.Id,
+			})
+		}
+	}
+
+	err = insertRecordsTx(ctx, txx, channelChaincode, channelChaincodes, true)
+	if err != nil {
+		return fmt.Errorf("add channel chaincodes to DB: %w", err)
+	}
+
+	return nil
+}
+
+// addBlocksTx adds blocks, raw blocks and moves checkpoints of channels to
+// the last block of batch.
+func addBlocksTx(ctx context.Context, txx *goqu.TxDatabase,
+	blocks []*hf.DecodedBlock, channelIDs map[string]int64) error {
+
+	var (
+		blockRows = make([]interface{}, 0, len(blocks))
+		rawBlocks []goqu.Record
+		numbers   = map[int64][]int64{}
+		last      = map[int64]int64{}
+	)
+
+	for _, b := range blocks {
+		channelID := channelIDs[b.ChannelName]
+
+		b.Block.ChannelId = channelID
+		blockRows = append(blockRows, b.Block)
+		numbers[channelID] = append(numbers[channelID], b.Block.Number)
+
+		if n, ok := last[channelID]; !ok || b.Block.Number > n {
+			last[channelID] = b.Block.Number
+		}
+
+		if b.RawBlock != nil {
+			b.RawBlock.ChannelId = channelID
+			rawBlocks = append(rawBlocks, rawBlockRecord(b.RawBlock))
+		}
+	}
+
+	_, err := txx.Insert(block).
+		Rows(blockRows...).
+		OnConflict(goqu.DoNothing()).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("add blocks to DB: %w", err)
+	}
+
+	ids := map[int64]map[int64]int64{}
+
+	for channelID, ns := range numbers {
+		ids[channelID] = map[int64]int64{}
+
+		rows, err := txx.From(block).
+			Select("id", "number").
+			Where(goqu.Ex{"channel_id": channelID, "number": ns}).
+			Executor().QueryContext(ctx)
+		if err != nil {
+			return fmt.Errorf("get blocks from DB: %w", err)
+		}
+
+		for rows.Next() {
+			var id, number int64
+			err = rows.Scan(&id, &number)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("scan block: %w", err)
+			}
+			ids[channelID][number] = id
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("get blocks from DB: %w", err)
+		}
+	}
+
+	for _, b := range blocks {
+		b.Block.Id = ids[b.Block.ChannelId][b.Block.Number]
+	}
+
+	err = insertRecordsTx(ctx, txx, blockRaw, rawBlocks, true)
+	if err != nil {
+		return fmt.Errorf("add raw blocks to DB: %w", err)
+	}
+
+	err = setLastBlockNumbersTx(ctx, txx, last)
+	if err != nil {
+		return fmt.Errorf("set last block numbers in DB: %w", err)
+	}
+
+	return nil
+}
+
+// addIdentitiesTx adds block signers and transaction creators, block
+// signatures and sets creator IDs of transactions.
+func addIdentitiesTx(ctx context.Context, txx *goqu.TxDatabase,
+	blocks []*hf.DecodedBlock) error {
+
+	var (
+		fingerprints []string
+		records      []goqu.Record
+		ids          = map[string]int64{}
+	)
+
+	add := func(i *explorer.Identity) {
+		if _, ok := ids[i.Fingerprint]; ok {
+			return
+		}
+		ids[i.Fingerprint] = 0
+		fingerprints = append(fingerprints, i.Fingerprint)
+		records = append(records, identityRecord(i))
+	}
+
+	for _, b := range blocks {
+		for _, s := range b.Signers {
+			add(s)
+		}
+		for _, c := range b.Creators {
+			if c != nil {
+				add(c)
+			}
+		}
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	err := insertRecordsTx(ctx, txx, identity, records, true)
+	if err != nil {
+		return fmt.Errorf("add identities to DB: %w", err)
+	}
+
+	rows, err := txx.From(identity).
+		Select("id", "fingerprint").
+		Where(goqu.Ex{"fingerprint": fingerprints}).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return fmt.Errorf("get identities from DB: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id          int64
+			fingerprint string
+		)
+		err = rows.Scan(&id, &fingerprint)
+		if err != nil {
+			return fmt.Errorf("scan identity: %w", err)
+		}
+		ids[fingerprint] = id
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("get identities from DB: %w", err)
+	}
+
+	var signatures []goqu.Record
+
+	for _, b := range blocks {
+		for _, s := range b.Signers {
+			signatures = append(signatures, goqu.Record{
+				"block_id":    b.Block.Id,
+				"identity_id": ids[s.Fingerprint],
+			})
+		}
+		for i, c := range b.Creators {
+			if c != nil {
+				b.Transactions[i].CreatorId = ids[c.Fingerprint]
+			}
+		}
+	}
+
+	err = insertRecordsTx(ctx, txx, blockSignature, signatures, true)
+	if err != nil {
+		return fmt.Errorf("add block signatures to DB: %w", err)
+	}
+
+	return nil
+}
+
+// addTransactionsTx adds transactions and entities which are only appended,
+// entities without references to them are copied.
+func addTransactionsTx(ctx context.Context, tx *sql.Tx,
+	txx *goqu.TxDatabase, blocks []*hf.DecodedBlock) error {
+
+	var (
+		transactions    []goqu.Record
+		arguments       []goqu.Record
+		endorsements    []goqu.Record
+		reads           []goqu.Record
+		rangeQueries    []hf.RangeQuery
+		writeHashes     []goqu.Record
+		writes          []goqu.Record
+		chaincodeEvents []goqu.Record
+	)
+
+	for _, b := range blocks {
+		for _, t := range b.Transactions {
+			t.ChannelId = b.Block.ChannelId
+			t.BlockId = b.Block.Id
+			transactions = append(transactions, transactionRecord(t))
+		}
+		for _, a := range b.Arguments {
+			a.BlockId = b.Block.Id
+			arguments = append(arguments, argumentRecord(a))
+		}
+		for _, en := range b.Endorsements {
+			en.BlockId = b.Block.Id
+			endorsements = append(endorsements, endorsementRecord(en))
+		}
+		for _, r := range b.Reads {
+			r.BlockId = b.Block.Id
+			reads = append(reads, readRecord(r))
+		}
+		for _, rq := range b.RangeQueries {
+			rq.RangeQuery.BlockId = b.Block.Id
+			for _, r := range rq.Reads {
+				r.BlockId = b.Block.Id
+			}
+			rangeQueries = append(rangeQueries, rq)
+		}
+		for _, pwh := range b.PrivateWriteHashes {
+			pwh.ChannelId = b.Block.ChannelId
+			writeHashes = append(writeHashes, privateWriteHashRecord(pwh))
+		}
+		for _, pw := range b.PrivateWrites {
+			pw.ChannelId = b.Block.ChannelId
+			writes = append(writes, privateWriteRecord(pw))
+		}
+		for _, ce := range b.ChaincodeEvents {
+			ce.ChannelId = b.Block.ChannelId
+			chaincodeEvents = append(chaincodeEvents, chaincodeEventRecord(ce))
+		}
+	}
+
+	err := insertRecordsTx(ctx, txx, transaction, transactions, false)
+	if err != nil {
+		return fmt.Errorf("add transactions to DB: %w", err)
+	}
+
+	if len(rangeQueries) > 0 {
+		ids, err := reserveIDsTx(ctx, txx, rangeQuery, len(rangeQueries))
+		if err != nil {
+			return fmt.Errorf("reserve range query IDs: %w", err)
+		}
+
+		records := make([]goqu.Record, 0, len(rangeQueries))
+		for i, rq := range rangeQueries {
+			rq.RangeQuery.Id = ids[i]
+			records = append(records, rangeQueryRecord(rq.RangeQuery))
+			for _, r := range rq.Reads {
+				r.RangeQueryId = rq.RangeQuery.Id
+				reads = append(reads, readRecord(r))
+			}
+		}
+
+		err = copyRecordsTx(ctx, tx, rangeQuery, records)
+		if err != nil {
+			return fmt.Errorf("add range queries to DB: %w", err)
+		}
+	}
+
+	for _, c := range []struct {
+		table   string
+		records []goqu.Record
+	}{
+		{argument, arguments},
+		{endorsement, endorsements},
+		{read, reads},
+		{privateWriteHash, writeHashes},
+		{privateWrite, writes},
+		{chaincodeEvent, chaincodeEvents},
+	} {
+		err = copyRecordsTx(ctx, tx, c.table, c.records)
+		if err != nil {
+			return fmt.Errorf("add %s rows to DB: %w", c.table, err)
+		}
+	}
+
+	return nil
+}
+
+// stateKeyOf is a key of state in channel.
+type stateKeyOf struct {
+	channelID int64
+	chaincode string
+	key       string
+}
+
+// actualState is a state of key while writes of batch are applied.
+type actualState struct {
+	exists bool
+	typ    string
+	// stored is true if state is stored in DB before batch.
+	stored bool
+	// replaced is true if stored state is replaced or deleted by batch.
+	replaced bool
+	// written is a state written by batch.
+	written *explorer.State
+
+	// versioned is true if key has actual state or was deleted, block and
+	// transaction numbers are version of the latest of them.
+	versioned   bool
+	blockNumber int64
+	txNumber    int64
+}
+
+// setVersion sets version of key if it is later than the current one.
+func (as *actualState) setVersion(blockNumber, txNumber int64) {
+	if as.versioned && (blockNumber < as.blockNumber ||
+		blockNumber == as.blockNumber && txNumber <= as.txNumber) {
+		return
+	}
+	as.versioned = true
+	as.blockNumber = blockNumber
+	as.txNumber = txNumber
+}
+
+// outdates reports whether key has later version than the write.
+func (as *actualState) outdates(sw hf.StateWrite) bool {
+	return as.versioned && sw.Precedes(as.blockNumber, as.txNumber)
+}
+
+// addStatesTx applies state writes of blocks in order. States existing before
+// batch are moved to old states at once, states overwritten inside batch are
+// added to old states directly, only the last states of keys are stored as
+// actual. Writes older than actual state or the last deletion of key, e.g.
+// writes of retried failed block, are added to old states only.
+func addStatesTx(ctx context.Context, tx *sql.Tx, txx *goqu.TxDatabase,
+	blocks []*hf.DecodedBlock) error {
+
+	var (
+		keys   []stateKeyOf
+		states = map[stateKeyOf]*actualState{}
+	)
+
+	for _, b := range blocks {
+		for _, s := range b.States {
+			s.State.ChannelId = b.Block.ChannelId
+			k := stateKeyOf{
+				channelID: s.State.ChannelId,
+				chaincode: s.State.Chaincode,
+				key:       s.State.Key,
+			}
+			if _, ok := states[k]; !ok {
+				states[k] = &actualState{}
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	where := statesWhere(keys)
+
+	rows, err := txx.From(state).
+		Select("channel_id", "chaincode", "key", "type",
+			"version_block_number", "version_tx_number").
+		Where(where).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return fmt.Errorf("get actual states from DB: %w", err)
+	}
+
+	for rows.Next() {
+		var (
+			k                     stateKeyOf
+			typ                   string
+			blockNumber, txNumber int64
+		)
+		err = rows.Scan(&k.channelID, &k.chaincode, &k.key, &typ,
+			&blockNumber, &txNumber)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan actual state: %w", err)
+		}
+		states[k].exists = true
+		states[k].stored = true
+		states[k].typ = typ
+		states[k].setVersion(blockNumber, txNumber)
+	}
+
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("get actual states from DB: %w", err)
+	}
+
+	rows, err = txx.From(oldState).
+		Select("channel_id", "chaincode", "key",
+			"version_block_number", "version_tx_number").
+		Distinct("channel_id", "chaincode", "key").
+		Where(where, goqu.Ex{"deleted": true}).
+		Order(goqu.C("channel_id").Asc(), goqu.C("chaincode").Asc(),
+			goqu.C("key").Asc(), goqu.C("version_block_number").Desc(),
+			goqu.C("version_tx_number").Desc()).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return fmt.Errorf("get last deleted states from DB: %w", err)
+	}
+
+	for rows.Next() {
+		var (
+			k                     stateKeyOf
+			blockNumber, txNumber int64
+		)
+		err = rows.Scan(&k.channelID, &k.chaincode, &k.key,
+			&blockNumber, &txNumber)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan last deleted state: %w", err)
+		}
+		states[k].setVersion(blockNumber, txNumber)
+	}
+
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("get last deleted states from DB: %w", err)
+	}
+
+	var oldStates []goqu.Record
+
+	for _, b := range blocks {
+		for _, s := range b.States {
+			as := states[stateKeyOf{
+				channelID: s.State.ChannelId,
+				chaincode: s.State.Chaincode,
+				key:       s.State.Key,
+			}]
+
+			if as.outdates(s) {
+				outdated := stateToOldState(s.State)
+				outdated.Deleted = s.IsDelete
+				oldStates = append(oldStates, oldStateRecord(outdated))
+				continue
+			}
+
+			as.setVersion(s.State.VersionBlockNumber,
+				s.State.VersionTxNumber)
+			as.replaced = as.stored
+
+			if as.written != nil {
+				oldStates = append(oldStates,
+					oldStateRecord(stateToOldState(as.written)))
+			}
+
+			if !s.IsDelete {
+				as.exists = true
+				as.typ = s.State.Type
+				as.written = s.State
+				continue
+			}
+
+			deleted := stateToOldState(s.State)
+			deleted.Deleted = true
+			if as.exists {
+				deleted.Type = as.typ
+			}
+			oldStates = append(oldStates, oldStateRecord(deleted))
+
+			as.exists = false
+			as.written = nil
+		}
+	}
+
+	var replaced []stateKeyOf
+
+	for _, k := range keys {
+		if states[k].replaced {
+			replaced = append(replaced, k)
+		}
+	}
+
+	if len(replaced) > 0 {
+		where = statesWhere(replaced)
+
+		err = moveStatesTx(ctx, txx, where)
+		if err != nil {
+			return fmt.Errorf("insert old states: %w", err)
+		}
+
+		_, err = txx.Delete(state).
+			Where(where).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("delete actual states: %w", err)
+		}
+	}
+
+	err = copyRecordsTx(ctx, tx, oldState, oldStates)
+	if err != nil {
+		return fmt.Errorf("insert old states: %w", err)
+	}
+
+	var actual []goqu.Record
+
+	for _, k := range keys {
+		if s := states[k].written; s != nil {
+			actual = append(actual, stateRecord(s))
+		}
+	}
+
+	err = insertRecordsTx(ctx, txx, state, actual, false)
+	if err != nil {
+		return fmt.Errorf("insert actual states: %w", err)
+	}
+
+	return nil
+}
+
+// statesWhere returns expression matching states with the given keys.
+func statesWhere(keys []stateKeyOf) exp.Expression {
+
+	var (
+		byChannel = map[int64][2][]string{}
+		channels  []int64
+		where     []exp.Expression
+	)
+
+	for _, k := range keys {
+		ck, ok := byChannel[k.channelID]
+		if !ok {
+			channels = append(channels, k.channelID)
+		}
+		ck[0] = append(ck[0], k.chaincode)
+		ck[1] = append(ck[1], k.key)
+		byChannel[k.channelID] = ck
+	}
+
+	for _, channelID := range channels {
+		ck := byChannel[channelID]
+		where = append(where, goqu.And(
+			goqu.Ex{"channel_id": channelID},
+			goqu.L("(chaincode, key) IN "+
+				"(SELECT * FROM unnest(?::text[], ?::text[]))",
+				pq.Array(ck[0]), pq.Array(ck[1])),
+		))
+	}
+
+	return goqu.Or(where...)
+}
+
+// reserveIDsTx returns count of next IDs of table serial ID column, so rows
+// referenced by other rows can be copied with IDs.
+func reserveIDsTx(ctx context.Context, txx *goqu.TxDatabase, table string,
+	count int) ([]int64, error) {
+
+	var ids []int64
+
+	err := txx.From(goqu.L("generate_series(1, ?)", count)).
+		Select(goqu.L("nextval(pg_get_serial_sequence(?, 'id'))", table)).
+		Executor().ScanValsContext(ctx, &ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// setLastBlockNumbersTx sets checkpoints of channels to the given block
+// numbers by channel ID. Checkpoint never moves back, so retry of old failed
+// block does not cause reprocessing of blocks after it.
+func setLastBlockNumbersTx(ctx context.Context, txx *goqu.TxDatabase,
+	numbers map[int64]int64) error {
+
+	checkpoints := make([]goqu.Record, 0, len(numbers))
+	for channelID, n := range numbers {
+		checkpoints = append(checkpoints, goqu.Record{
+			"channel_id":   channelID,
+			"block_number": n,
+		})
+	}
+
+	_, err := txx.Insert(checkpoint).
+		Rows(checkpoints).
+		OnConflict(goqu.DoUpdate("channel_id", goqu.Record{
+			"block_number": goqu.L("GREATEST(?, ?)",
+				goqu.I(checkpoint+".block_number"),
+				goqu.I("excluded.block_number")),
+		})).
+		Executor().ExecContext(ctx)
+	return err
+}
+
+// insertRecordsTx inserts records with multi-row inserts of up to
+// insertChunkSize rows. Conflicting rows are skipped if skipConflicts is true.
+func insertRecordsTx(ctx context.Context, txx *goqu.TxDatabase, table string,
+	records []goqu.Record, skipConflicts bool) error {
+
+	for len(records) > 0 {
+		n := len(records)
+		if n > insertChunkSize {
+			n = insertChunkSize
+		}
+
+		q := txx.Insert(table).Rows(records[:n])
+		if skipConflicts {
+			q = q.OnConflict(goqu.DoNothing())
+		}
+
+		_, err := q.Executor().ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		records = records[n:]
+	}
+
+	return nil
+}
+
+// copyRecordsTx copies records to table with COPY. Byte slices are passed as
+// text, so bytea columns are stored the same way as with inserts.
+func copyRecordsTx(ctx context.Context, tx *sql.Tx, table string,
+	records []goqu.Record) (err error) {
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	columns := make([]string, 0, len(records[0]))
+	for c := range records[0] {
+		columns = append(columns, c)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err2 := stmt.Close()
+		if err == nil {
+			err = err2
+		}
+	}()
+
+	values := make([]interface{}, len(columns))
+
+	for _, r := range records {
+		for i, c := range columns {
+			v := r[c]
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			values[i] = v
+		}
+		_, err = stmt.ExecContext(ctx, values...)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
@@ -0,0 +1,366 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"explorer"
+	"explorer/hf"
+)
+
+// benchBlock returns block of 10 transactions, each with arguments,
+// endorsements, reads and writes of keys, which are rewritten every 100
+// blocks.
+func benchBlock(channelName string, number int64) *hf.DecodedBlock {
+
+	now := timestamppb.New(time.Now())
+
+	org := func(n int) *explorer.Identity {
+		return &explorer.Identity{
+			MspId:       fmt.Sprintf("Org%dMSP", n),
+			Fingerprint: fmt.Sprintf("fingerprint%d", n),
+			Subject:     fmt.Sprintf("CN=peer0.org%d.example.com", n),
+			NotAfter:    now,
+		}
+	}
+
+	b := &hf.DecodedBlock{
+		ChannelName: channelName,
+		SourceURL:   "grpcs://peer0.org1.example.com:7051",
+		Block: &explorer.Block{
+			Number:           number,
+			Hash:             fmt.Sprintf("%064x", number),
+			TransactionCount: 10,
+		},
+		Signers:    []*explorer.Identity{org(0)},
+		Chaincodes: []*explorer.Chaincode{{Name: "basic", Version: "1"}},
+	}
+
+	for i := int32(0); i < 10; i++ {
+		t := &explorer.Transaction{
+			Id:             fmt.Sprintf("%064x", number*10+int64(i)),
+			Number:         i,
+			CreatedAt:      now,
+			ValidationCode: "VALID",
+			Function:       "TransferAsset",
+			Type:           "ENDORSER_TRANSACTION",
+		}
+
+		b.Transactions = append(b.Transactions, t)
+		b.Creators = append(b.Creators, org(1))
+
+		for j := int32(0); j < 2; j++ {
+			b.Arguments = append(b.Arguments, &explorer.Argument{
+				TransactionId:     t.Id,
+				TransactionNumber: i,
+				Index:             j,
+				Type:              "string",
+				RawValue:          []byte("asset"),
+				Value:             []byte(`"asset"`),
+			})
+			b.Endorsements = append(b.Endorsements, &explorer.Endorsement{
+				TransactionId:     t.Id,
+				TransactionNumber: i,
+				MspId:             fmt.Sprintf("Org%dMSP", j+1),
+				Fingerprint:       fmt.Sprintf("fingerprint%d", j+1),
+			})
+		}
+
+		key := fmt.Sprintf("asset%d", (number%100)*10+int64(i))
+
+		for j := 0; j < 3; j++ {
+			b.Reads = append(b.Reads, &explorer.Read{
+				TransactionId:     t.Id,
+				TransactionNumber: i,
+				Chaincode:         "basic",
+				Key:               key,
+				HasVersion:        true,
+			})
+		}
+
+		b.States = append(b.States, hf.StateWrite{State: &explorer.State{
+			Key:                key,
+			Chaincode:          "basic",
+			TransactionId:      t.Id,
+			Type:               "asset",
+			RawValue:           []byte(`{"owner":"Tom"}`),
+			Value:              []byte(`{"owner":"Tom"}`),
+			CreatedAt:          now,
+			VersionBlockNumber: number,
+			VersionTxNumber:    int64(i),
+		}})
+	}
+
+	return b
+}
+
+// storeBlocksPerRowTx stores blocks with a statement per row. It is a
+// baseline of benchmark, not a copy of any former processor code.
+func storeBlocksPerRowTx(ctx context.Context, _ *sql.Tx,
+	txx *goqu.TxDatabase, blocks []*hf.DecodedBlock) error {
+
+	for _, b := range blocks {
+		peerID, err := upsertIDTx(ctx, txx, peer,
+			goqu.Record{"url": b.SourceURL})
+		if err != nil {
+			return err
+		}
+
+		channelID, err := upsertIDTx(ctx, txx, channel,
+			goqu.Record{"name": b.ChannelName})
+		if err != nil {
+			return err
+		}
+
+		_, err = txx.Insert(peerChannel).
+			Rows(goqu.Record{
+				"peer_id":    peerID,
+				"channel_id": channelID,
+			}).
+			OnConflict(goqu.DoNothing()).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range b.Chaincodes {
+			c.Id, err = upsertIDTx(ctx, txx, chaincode, goqu.Record{
+				"name":    c.Name,
+				"version": c.Version,
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = txx.Insert(channelChaincode).
+				Rows(goqu.Record{
+					"channel_id":   channelID,
+					"chaincode_id": c.Id,
+				}).
+				OnConflict(goqu.DoNothing()).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		b.Block.ChannelId = channelID
+
+		_, err = txx.Insert(block).
+			Rows(b.Block).
+			Returning("id").
+			Executor().ScanValContext(ctx, &b.Block.Id)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range b.Signers {
+			identityID, err := upsertIdentityTx(ctx, txx, s)
+			if err != nil {
+				return err
+			}
+
+			_, err = txx.Insert(blockSignature).
+				Rows(goqu.Record{
+					"block_id":    b.Block.Id,
+					"identity_id": identityID,
+				}).
+				OnConflict(goqu.DoNothing()).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = setLastBlockNumbersTx(ctx, txx,
+			map[int64]int64{channelID: b.Block.Number})
+		if err != nil {
+			return err
+		}
+
+		var transactions, arguments, endorsements, reads []goqu.Record
+
+		for i, t := range b.Transactions {
+			t.ChannelId = channelID
+			t.BlockId = b.Block.Id
+			if c := b.Creators[i]; c != nil {
+				t.CreatorId, err = upsertIdentityTx(ctx, txx, c)
+				if err != nil {
+					return err
+				}
+			}
+			transactions = append(transactions, transactionRecord(t))
+		}
+		for _, a := range b.Arguments {
+			a.BlockId = b.Block.Id
+			arguments = append(arguments, argumentRecord(a))
+		}
+		for _, en := range b.Endorsements {
+			en.BlockId = b.Block.Id
+			endorsements = append(endorsements, endorsementRecord(en))
+		}
+		for _, r := range b.Reads {
+			r.BlockId = b.Block.Id
+			reads = append(reads, readRecord(r))
+		}
+
+		for _, c := range []struct {
+			table   string
+			records []goqu.Record
+		}{
+			{transaction, transactions},
+			{argument, arguments},
+			{endorsement, endorsements},
+			{read, reads},
+		} {
+			for _, r := range c.records {
+				_, err = txx.Insert(c.table).
+					Rows(r).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, s := range b.States {
+			s.State.ChannelId = channelID
+
+			key := goqu.Ex{
+				"channel_id": s.State.ChannelId,
+				"chaincode":  s.State.Chaincode,
+				"key":        s.State.Key,
+			}
+
+			err = moveStatesTx(ctx, txx, key)
+			if err != nil {
+				return err
+			}
+
+			_, err = txx.Delete(state).
+				Where(key).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = txx.Insert(state).
+				Rows(stateRecord(s.State)).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// upsertIDTx adds row if it not exists and returns its ID, row is found by
+// all its columns.
+func upsertIDTx(ctx context.Context, txx *goqu.TxDatabase, table string,
+	r goqu.Record) (id int64, err error) {
+
+	_, err = txx.Insert(table).
+		Rows(r).
+		OnConflict(goqu.DoNothing()).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = txx.From(table).
+		Select("id").
+		Where(goqu.Ex(r)).
+		ScanValContext(ctx, &id)
+	return id, err
+}
+
+// upsertIdentityTx adds identity if it not exists and returns its ID.
+func upsertIdentityTx(ctx context.Context, txx *goqu.TxDatabase,
+	i *explorer.Identity) (id int64, err error) {
+
+	_, err = txx.Insert(identity).
+		Rows(identityRecord(i)).
+		OnConflict(goqu.DoNothing()).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = txx.From(identity).
+		Select("id").
+		Where(goqu.Ex{"fingerprint": i.Fingerprint}).
+		ScanValContext(ctx, &id)
+	return id, err
+}
+
+type storeBlocksFunc func(ctx context.Context, tx *sql.Tx,
+	txx *goqu.TxDatabase, blocks []*hf.DecodedBlock) error
+
+func benchmarkStoreBlocks(b *testing.B, db *goqu.Database,
+	store storeBlocksFunc, batchSize int) {
+
+	var (
+		ctx         = context.Background()
+		channelName = testChannel(b, db, "bench")
+		blocks      []*hf.DecodedBlock
+	)
+
+	for i := 0; i < b.N; i++ {
+		blocks = append(blocks, benchBlock(channelName, int64(i)))
+	}
+
+	b.ResetTimer()
+
+	start := time.Now()
+
+	for len(blocks) > 0 {
+		n := batchSize
+		if n > len(blocks) {
+			n = len(blocks)
+		}
+
+		tx, err := db.Db.BeginTx(ctx, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		err = store(ctx, tx, goqu.NewTx(postgresDialect, tx), blocks[:n])
+		if err != nil {
+			tx.Rollback()
+			b.Fatal(err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		blocks = blocks[n:]
+	}
+
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "blocks/s")
+}
+
+// BenchmarkStoreBlocks compares throughput of storing blocks with a statement
+// per row and with storeBlocksTx in batches.
+func BenchmarkStoreBlocks(b *testing.B) {
+	db := testDB(b)
+
+	b.Run("per_row", func(b *testing.B) {
+		benchmarkStoreBlocks(b, db, storeBlocksPerRowTx, 1)
+	})
+
+	for _, batchSize := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("bulk_batch_%d", batchSize), func(b *testing.B) {
+			benchmarkStoreBlocks(b, db, storeBlocksTx, batchSize)
+		})
+	}
+}
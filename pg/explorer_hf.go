@@ -5,94 +5,89 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
-	"time"
-
-	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
 
 	"explorer"
+	"explorer/hf"
 )
 
-func (e *Explorer) LastBlockID(ctx context.Context) (lastID int64, err error) {
-	found, err := e.db.
-		From("block").
-		Select(goqu.COALESCE(goqu.MAX("id"), 0)).
-		ScanValContext(ctx, &lastID)
-	if err != nil {
-		return
-	}
-	if !found {
-		lastID = 0
-	}
-	return
+// storage stores data of processors in Postgres.
+type storage struct {
+	db  *goqu.Database
+	log *logrus.Entry
 }
 
-const (
-	postgresDialect = "postgres"
-
-	peer             = "peer"
-	channel          = "channel"
-	peerChannel      = "peer_channel"
-	channelConfig    = "channel_config"
-	chaincode        = "chaincode"
-	channelChaincode = "channel_chaincode"
-	block            = "block"
-	transaction      = "transaction"
-	state            = "state"
-	oldState         = "old_state"
-)
+func (s *storage) LastBlockNumber(ctx context.Context, channelName string) (
+	number int64, found bool, err error) {
 
-func (e *Explorer) BeginTx(ctx context.Context) (*sql.Tx, error) {
-	return e.db.Db.BeginTx(ctx, nil)
+	found, err = s.db.
+		From(goqu.I(checkpoint).As("cp")).
+		Join(goqu.I(channel).As("c"),
+			goqu.On(goqu.Ex{"cp.channel_id": goqu.I("c.id")})).
+		Select("cp.block_number").
+		Where(goqu.Ex{"c.name": channelName}).
+		ScanValContext(ctx, &number)
+	return
 }
 
-func (e *Explorer) AddPeerTx(ctx context.Context, tx *sql.Tx,
-	p *explorer.Peer) (id int64, err error) {
+// RawBlocks returns stored raw blocks of the channel starting from the given
+// block number in number order.
+func (s *storage) RawBlocks(ctx context.Context, channelName string,
+	fromNumber int64, limit int) ([]*explorer.RawBlock, error) {
+
+	var rbs []*explorer.RawBlock
+
+	err := s.db.
+		From(goqu.I(blockRaw).As("br")).
+		Join(goqu.I(channel).As("c"),
+			goqu.On(goqu.Ex{"br.channel_id": goqu.I("c.id")})).
+		Select("br.channel_id", "br.number", "br.compression",
+			"br.source_url", "br.data").
+		Where(goqu.Ex{
+			"c.name":    channelName,
+			"br.number": goqu.Op{"gte": fromNumber},
+		}).
+		OrderAppend(goqu.I("br.number").Asc()).
+		Limit(uint(limit)).
+		ScanStructsContext(ctx, &rbs)
+	if err != nil {
+		return nil, err
+	}
 
-	defer func() {
-		if err != nil {
-			err2 := tx.Rollback()
-			if err2 != nil {
-				e.log.WithError(err2).
-					Error("failed to rollback transaction")
-			}
-		}
-	}()
+	return rbs, nil
+}
 
-	txx := goqu.NewTx(postgresDialect, tx)
+// ResetChannel removes all indexed data of the channel except raw blocks,
+// so the channel can be reindexed from them.
+func (s *storage) ResetChannel(ctx context.Context,
+	channelName string) (err error) {
 
-	exists, err := txx.Select("id").
-		From(peer).
-		Where(goqu.Ex{"url": p.Url}).
-		ScanValContext(ctx, &id)
+	var channelID int64
+
+	found, err := s.db.From(channel).
+		Select("id").
+		Where(goqu.Ex{"name": channelName}).
+		ScanValContext(ctx, &channelID)
 	if err != nil {
-		return 0, fmt.Errorf("get peer from DB: %w", err)
+		return fmt.Errorf("get channel from DB: %w", err)
 	}
-	if exists {
-		return id, nil
+	if !found {
+		return nil
 	}
 
-	_, err = txx.
-		Insert(peer).
-		Rows(p).
-		Returning("id").
-		Executor().ScanValContext(ctx, &id)
+	tx, err := s.db.Db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("add peer to DB: %w", err)
+		return fmt.Errorf("begin transaction: %w", err)
 	}
 
-	return id, nil
-}
-
-func (e *Explorer) AddChannelTx(ctx context.Context, tx *sql.Tx,
-	c *explorer.Channel) (id int64, err error) {
-
 	defer func() {
 		if err != nil {
 			err2 := tx.Rollback()
 			if err2 != nil {
-				e.log.WithError(err2).
+				s.log.WithError(err2).
 					Error("failed to rollback transaction")
 			}
 		}
@@ -100,275 +95,338 @@ func (e *Explorer) AddChannelTx(ctx context.Context, tx *sql.Tx,
 
 	txx := goqu.NewTx(postgresDialect, tx)
 
-	exists, err := txx.Select("id").
-		From(channel).
-		Where(goqu.Ex{"name": c.Name}).
-		ScanValContext(ctx, &id)
-	if err != nil {
-		return 0, fmt.Errorf("get channel from DB: %w", err)
-	}
-	if exists {
-		return id, nil
+	byChannel := goqu.Ex{"channel_id": channelID}
+
+	byBlock := goqu.Ex{"block_id": txx.From(block).
+		Select("id").Where(byChannel)}
+
+	byChannelConfig := goqu.Ex{"channel_config_id": txx.From(channelConfig).
+		Select("id").Where(byChannel)}
+
+	byChaincodeDefinition := goqu.Ex{"chaincode_definition_id": txx.
+		From(chaincodeDefinition).Select("id").Where(byChannel)}
+
+	// Tables are cleared in order of references between them.
+	for _, d := range []struct {
+		table string
+		where goqu.Ex
+	}{
+		{chaincodeApproval, byChaincodeDefinition},
+		{chaincodeDefinition, byChannel},
+		{argument, byBlock},
+		{endorsement, byBlock},
+		{read, byBlock},
+		{rangeQuery, byBlock},
+		{privateWriteHash, byChannel},
+		{privateWrite, byChannel},
+		{chaincodeEvent, byChannel},
+		{state, byChannel},
+		{oldState, byChannel},
+		{transaction, byChannel},
+		{blockSignature, byBlock},
+		{block, byChannel},
+		{channelOrganization, byChannelConfig},
+		{channelPolicy, byChannelConfig},
+		{channelConfig, byChannel},
+		{checkpoint, byChannel},
+		{failedBlock, byChannel},
+	} {
+		_, err = txx.Delete(d.table).
+			Where(d.where).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("delete from %s: %w", d.table, err)
+		}
 	}
 
-	_, err = txx.
-		Insert(channel).
-		Rows(c).
-		Returning("id").
-		Executor().ScanValContext(ctx, &id)
+	err = tx.Commit()
 	if err != nil {
-		return 0, fmt.Errorf("add channel to DB: %w", err)
+		return fmt.Errorf("commit transaction: %w", err)
 	}
 
-	return id, nil
+	return nil
 }
 
-func (e *Explorer) AddChannelConfigTx(ctx context.Context, tx *sql.Tx,
-	cc *explorer.ChannelConfig) error {
+const (
+	peer             = "peer"
+	channel          = "channel"
+	peerChannel      = "peer_channel"
+	channelConfig    = "channel_config"
+	chaincode        = "chaincode"
+	channelChaincode = "channel_chaincode"
+	block            = "block"
+	blockSignature   = "block_signature"
+	blockRaw         = "block_raw"
+	checkpoint       = "checkpoint"
+	transaction      = "transaction"
+	identity         = "identity"
+	argument         = "argument"
+	endorsement      = "endorsement"
+	rangeQuery       = "range_query"
+	read             = "read"
+	privateWriteHash = "private_write_hash"
+	privateWrite     = "private_write"
+	chaincodeEvent   = "chaincode_event"
+	state            = "state"
+	oldState         = "old_state"
+
+	chaincodeDefinition = "chaincode_definition"
+	chaincodeApproval   = "chaincode_approval"
+	channelOrganization = "channel_organization"
+	channelPolicy       = "channel_policy"
+	failedBlock         = "failed_block"
+)
 
-	_, err := goqu.NewTx(postgresDialect, tx).
-		Insert(channelConfig).
-		Rows(goqu.Record{
-			"channel_id": cc.ChannelId,
-			"raw":        hex.EncodeToString(cc.Raw),
-			"parsed":     cc.Parsed,
-			"created_at": cc.CreatedAt.AsTime()}).
-		Executor().ExecContext(ctx)
+// Begin begins database transaction as storage unit of work.
+func (s *storage) Begin(ctx context.Context) (hf.StorageTx, error) {
+	tx, err := s.db.Db.BeginTx(ctx, nil)
 	if err != nil {
-		err2 := tx.Rollback()
-		if err2 != nil {
-			e.log.WithError(err2).Error("failed to rollback transaction")
-		}
+		return nil, err
 	}
-	return err
+	return &storageTx{
+		tx:  tx,
+		txx: goqu.NewTx(postgresDialect, tx),
+	}, nil
 }
 
-func (e *Explorer) AddPeerChannelTx(ctx context.Context, tx *sql.Tx,
-	c *explorer.PeerChannel) error {
+// storageTx is a storage unit of work in database transaction.
+type storageTx struct {
+	tx  *sql.Tx
+	txx *goqu.TxDatabase
+}
 
-	_, err := goqu.NewTx(postgresDialect, tx).
-		Insert(peerChannel).
-		Rows(c).
-		OnConflict(goqu.DoNothing()).
-		Executor().ExecContext(ctx)
-	if err != nil {
-		err2 := tx.Rollback()
-		if err2 != nil {
-			e.log.WithError(err2).Error("failed to rollback transaction")
-		}
-	}
+func (t *storageTx) WriteBlocks(ctx context.Context,
+	blocks []*hf.DecodedBlock) error {
 
-	return err
+	return storeBlocksTx(ctx, t.tx, t.txx, blocks)
 }
 
-func (e *Explorer) AddChaincodeTx(ctx context.Context, tx *sql.Tx,
-	c *explorer.Chaincode) (id int64, err error) {
+func (t *storageTx) WriteFailedBlock(ctx context.Context, channelName string,
+	fb *explorer.FailedBlock, rb *explorer.RawBlock) error {
 
-	defer func() {
+	channelID, err := channelIDTx(ctx, t.txx, channelName)
+	if err != nil {
+		return err
+	}
+
+	if rb != nil {
+		rb.ChannelId = channelID
+		err = insertRecordsTx(ctx, t.txx, blockRaw,
+			[]goqu.Record{rawBlockRecord(rb)}, true)
 		if err != nil {
-			err2 := tx.Rollback()
-			if err2 != nil {
-				e.log.WithError(err2).
-					Error("failed to rollback transaction")
-			}
+			return fmt.Errorf("add raw block to DB: %w", err)
 		}
-	}()
+	}
 
-	txx := goqu.NewTx(postgresDialect, tx)
+	fb.ChannelId = channelID
 
-	exists, err := txx.
-		Select("id").
-		From(chaincode).
-		Where(goqu.Ex{"name": c.Name, "version": c.Version}).
-		ScanValContext(ctx, &id)
+	_, err = t.txx.
+		Insert(failedBlock).
+		Rows(goqu.Record{
+			"channel_id":  fb.ChannelId,
+			"number":      fb.Number,
+			"compression": fb.Compression,
+			"source_url":  fb.SourceUrl,
+			"data": goqu.L("decode(?, 'hex')",
+				hex.EncodeToString(fb.Data)),
+			"error":    fb.Error,
+			"failures": fb.Failures,
+		}).
+		OnConflict(goqu.DoUpdate("channel_id, number", goqu.Record{
+			"compression": goqu.I("excluded.compression"),
+			"source_url":  goqu.I("excluded.source_url"),
+			"data":        goqu.I("excluded.data"),
+			"error":       goqu.I("excluded.error"),
+			"failures": goqu.L("? + ?", goqu.I(failedBlock+".failures"),
+				goqu.I("excluded.failures")),
+		})).
+		Executor().ExecContext(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("get peer from DB: %w", err)
-	}
-	if exists {
-		return id, nil
+		return fmt.Errorf("add failed block to DB: %w", err)
 	}
 
-	_, err = txx.
-		Insert(chaincode).
-		Rows(c).
-		Returning("id").
-		Executor().ScanValContext(ctx, &id)
+	err = setLastBlockNumbersTx(ctx, t.txx,
+		map[int64]int64{channelID: fb.Number})
 	if err != nil {
-		return 0, fmt.Errorf("add chaincode to DB: %w", err)
+		return fmt.Errorf("set last block number in DB: %w", err)
 	}
 
-	return id, nil
+	return nil
 }
 
-func (e *Explorer) AddChannelChaincodeTx(ctx context.Context, tx *sql.Tx,
-	cc *explorer.ChannelChaincode) error {
+func (t *storageTx) DeleteFailedBlock(ctx context.Context, channelName string,
+	number int64) error {
+
+	channelID, err := channelIDTx(ctx, t.txx, channelName)
+	if err != nil {
+		return err
+	}
 
-	_, err := goqu.NewTx(postgresDialect, tx).
-		Insert(channelChaincode).
-		Rows(cc).
-		OnConflict(goqu.DoNothing()).
+	_, err = t.txx.
+		Delete(failedBlock).
+		Where(goqu.Ex{
+			"channel_id": channelID,
+			"number":     number,
+		}).
 		Executor().ExecContext(ctx)
 	if err != nil {
-		err2 := tx.Rollback()
-		if err2 != nil {
-			e.log.WithError(err2).Error("failed to rollback transaction")
-		}
+		return fmt.Errorf("delete failed block from DB: %w", err)
 	}
 
-	return err
+	return nil
 }
 
-func (e *Explorer) AddBlockTx(ctx context.Context, tx *sql.Tx,
-	b *explorer.Block) (id int64, err error) {
+func (t *storageTx) Commit() error {
+	return t.tx.Commit()
+}
 
-	defer func() {
-		if err != nil {
-			err2 := tx.Rollback()
-			if err2 != nil {
-				e.log.WithError(err2).
-					Error("failed to rollback transaction")
-			}
-		}
-	}()
+func (t *storageTx) Rollback() error {
+	return t.tx.Rollback()
+}
 
-	txx := goqu.NewTx(postgresDialect, tx)
+// channelIDTx returns ID of the channel adding it if it is not exists yet.
+func channelIDTx(ctx context.Context, txx *goqu.TxDatabase,
+	channelName string) (int64, error) {
 
-	exists, err := txx.
-		Select("id").
-		From(block).
-		Where(goqu.Ex{"channel_id": b.ChannelId, "number": b.Number}).
-		Executor().ScanValContext(ctx, &id)
+	ids := map[string]int64{}
+
+	err := upsertIDsTx(ctx, txx, channel, "name", []string{channelName}, ids)
 	if err != nil {
-		return 0, fmt.Errorf("get peer from DB: %w", err)
-	}
-	if exists {
-		return id, nil
+		return 0, fmt.Errorf("add channel to DB: %w", err)
 	}
 
-	fmt.Println(b)
+	return ids[channelName], nil
+}
+
+func addChannelConfigTx(ctx context.Context, txx *goqu.TxDatabase,
+	cc *explorer.ChannelConfig) (id int64, err error) {
 
 	_, err = txx.
-		Insert(block).
-		Rows(b).
+		Insert(channelConfig).
+		Rows(goqu.Record{
+			"channel_id":                cc.ChannelId,
+			"raw":                       hex.EncodeToString(cc.Raw),
+			"parsed":                    cc.Parsed,
+			"created_at":                cc.CreatedAt.AsTime(),
+			"sequence":                  cc.Sequence,
+			"consensus_type":            cc.ConsensusType,
+			"batch_max_message_count":   cc.BatchMaxMessageCount,
+			"batch_absolute_max_bytes":  cc.BatchAbsoluteMaxBytes,
+			"batch_preferred_max_bytes": cc.BatchPreferredMaxBytes,
+			"batch_timeout":             cc.BatchTimeout,
+			"orderer_addresses":         textArray(cc.OrdererAddresses),
+		}).
 		Returning("id").
 		Executor().ScanValContext(ctx, &id)
-	if err != nil {
-		return 0, fmt.Errorf("add peer to DB: %w", err)
-	}
-
-	return id, nil
+	return id, err
 }
 
-func (e *Explorer) AddTransactionTx(ctx context.Context, tx *sql.Tx,
-	t *explorer.Transaction) error {
+// addChaincodeDefinitionTx adds chaincode definition if it is not exists
+// yet. Definitions are keyed by their parameters hash, so approvals of
+// different parameters of the same sequence are stored for different
+// definitions and only matching approvals are stored for committed one.
+func addChaincodeDefinitionTx(ctx context.Context, txx *goqu.TxDatabase,
+	cd *explorer.ChaincodeDefinition) (id int64, err error) {
+
+	cdKey := goqu.Ex{
+		"channel_id":      cd.ChannelId,
+		"name":            cd.Name,
+		"sequence":        cd.Sequence,
+		"parameters_hash": cd.ParametersHash,
+	}
 
-	_, err := goqu.NewTx(postgresDialect, tx).
-		Insert(transaction).
-		Rows(goqu.Record{
-			"id":         t.Id,
-			"channel_id": t.ChannelId,
-			"block_id":   t.BlockId,
-			"created_at": t.CreatedAt.AsTime(),
-		}).
-		OnConflict(goqu.DoNothing()).
-		Executor().ExecContext(ctx)
+	exists, err := txx.Select("id").
+		From(chaincodeDefinition).
+		Where(cdKey).
+		ScanValContext(ctx, &id)
 	if err != nil {
-		err2 := tx.Rollback()
-		if err2 != nil {
-			e.log.WithError(err2).Error("failed to rollback transaction")
-		}
+		return 0, fmt.Errorf("get chaincode definition from DB: %w", err)
+	}
+	if exists && cd.TransactionId == "" {
+		return id, nil
 	}
 
-	return err
-}
-
-func (e *Explorer) AddStateTx(ctx context.Context, tx *sql.Tx,
-	as *explorer.State) (err error) {
+	cdr := goqu.Record{
+		"channel_id":         cd.ChannelId,
+		"name":               cd.Name,
+		"sequence":           cd.Sequence,
+		"version":            cd.Version,
+		"endorsement_plugin": cd.EndorsementPlugin,
+		"validation_plugin":  cd.ValidationPlugin,
+		"init_required":      cd.InitRequired,
+		"parameters_hash":    cd.ParametersHash,
+	}
+	if len(cd.EndorsementPolicy) > 0 {
+		cdr["endorsement_policy"] = cd.EndorsementPolicy
+	}
+	if len(cd.Collections) > 0 {
+		cdr["collections"] = cd.Collections
+	}
+	if cd.TransactionId != "" {
+		cdr["transaction_id"] = cd.TransactionId
+		cdr["committed_at"] = cd.CommittedAt.AsTime()
+	}
 
-	defer func() {
+	if exists {
+		_, err = txx.Update(chaincodeDefinition).
+			Set(cdr).
+			Where(goqu.Ex{"id": id}).
+			Executor().ExecContext(ctx)
 		if err != nil {
-			err2 := tx.Rollback()
-			if err2 != nil {
-				e.log.WithError(err2).
-					Error("failed to rollback transaction")
-			}
+			return 0, fmt.Errorf("update chaincode definition in DB: %w", err)
 		}
-	}()
-
-	txx := goqu.NewTx(postgresDialect, tx)
-
-	var os explorer.State
+		return id, nil
+	}
 
-	rows, err := txx.Select("key", "channel_id", "transaction_id",
-		"type", "raw_value", "value", "created_at").
-		From(state).
-		Where(goqu.Ex{"key": as.Key}).
-		Executor().Query()
+	_, err = txx.
+		Insert(chaincodeDefinition).
+		Rows(cdr).
+		Returning("id").
+		Executor().ScanValContext(ctx, &id)
 	if err != nil {
-		return fmt.Errorf("get actual state: %w", err)
+		return 0, fmt.Errorf("add chaincode definition to DB: %w", err)
 	}
 
-	defer rows.Close()
+	return id, nil
+}
 
-	var exists bool
-	if rows.Next() {
-		exists = true
-		var createdAt time.Time
-		err = rows.Scan(&os.Key, &os.ChannelId, &os.TransactionId, &os.Type,
-			&os.RawValue, &os.Value, &createdAt)
-		if err != nil {
-			return err
-		}
-		os.CreatedAt = timestamppb.New(createdAt)
+// nullID returns nil for zero ID so it is stored as NULL reference.
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
 	}
+	return id
+}
 
-	ar := goqu.Record{
-		"key":            as.Key,
-		"channel_id":     as.ChannelId,
-		"transaction_id": as.TransactionId,
-		"type":           as.Type,
-		"raw_value":      hex.EncodeToString(as.RawValue),
-		"created_at":     as.CreatedAt.AsTime(),
-	}
-	if len(as.Value) > 0 {
-		ar["value"] = as.Value
+func stateToOldState(s *explorer.State) *explorer.OldState {
+	return &explorer.OldState{
+		ChannelId:     s.ChannelId,
+		TransactionId: s.TransactionId,
+		Key:           s.Key,
+		Chaincode:     s.Chaincode,
+		Type:          s.Type,
+		RawValue:      s.RawValue,
+		Value:         s.Value,
+		CreatedAt:     s.CreatedAt,
+
+		VersionBlockNumber: s.VersionBlockNumber,
+		VersionTxNumber:    s.VersionTxNumber,
 	}
+}
 
-	if exists {
-
-		or := goqu.Record{
-			"key":            os.Key,
-			"channel_id":     os.ChannelId,
-			"transaction_id": os.TransactionId,
-			"type":           os.Type,
-			"raw_value":      hex.EncodeToString(os.RawValue),
-			"created_at":     os.CreatedAt.AsTime(),
-		}
-		if len(as.Value) > 0 {
-			or["value"] = os.Value
-		}
-
-		_, err = txx.
-			Insert(oldState).
-			Rows(or).Executor().Exec()
-		if err != nil {
-			return fmt.Errorf("insert old state: %w", err)
-		}
-		_, err = txx.Update(state).
-			Where(goqu.Ex{"key": as.Key}).
-			Set(ar).
-			Executor().Exec()
-		if err != nil {
-			return fmt.Errorf("update actual state: %w", err)
-		}
-	} else {
-		_, err := txx.
-			Insert(state).
-			Rows(ar).Executor().Exec()
-		if err != nil {
-			return fmt.Errorf("insert actual state: %w", err)
-		}
+// nullBytes returns nil for empty bytes so they are stored as NULL.
+func nullBytes(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
 	}
+	return hex.EncodeToString(b)
+}
 
-	return nil
+// textArray returns postgres text array value, nil slice is stored as empty
+// array.
+func textArray(ss []string) interface{} {
+	if ss == nil {
+		ss = []string{}
+	}
+	return pq.Array(ss)
 }
@@ -0,0 +1,187 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/sirupsen/logrus"
+
+	"explorer/hf"
+	"explorer/hf/storagetest"
+)
+
+// testDSNEnv is an environment variable with DSN of Postgres database used
+// by tests and benchmarks, they are skipped if it is not set. Database is
+// migrated, test blocks are stored in new channels which are removed after
+// test.
+const testDSNEnv = "EXPLORER_TEST_DSN"
+
+func testDB(tb testing.TB) *goqu.Database {
+	tb.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		tb.Skipf("%s is not set", testDSNEnv)
+	}
+
+	sqlDB, err := sql.Open(postgresDialect, dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	err = backend{}.Migrate(sqlDB)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	sqlDB, err = sql.Open(postgresDialect, dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	return goqu.New(postgresDialect, sqlDB)
+}
+
+// testChannel returns name of new channel, which is removed with all its
+// data after test.
+func testChannel(tb testing.TB, db *goqu.Database, prefix string) string {
+	tb.Helper()
+
+	channelName := fmt.Sprintf("%s%d", prefix, time.Now().UnixNano())
+
+	tb.Cleanup(func() {
+		ctx := context.Background()
+
+		s := backend{}.Storage(db, logrus.WithField("test", tb.Name()))
+
+		err := s.ResetChannel(ctx, channelName)
+		if err != nil {
+			tb.Errorf("reset channel `%s`: %v", channelName, err)
+			return
+		}
+
+		byChannel := goqu.Ex{"channel_id": db.From(channel).
+			Select("id").Where(goqu.Ex{"name": channelName})}
+
+		// Reset keeps raw blocks and peers and chaincodes of channel.
+		for _, d := range []struct {
+			table string
+			where goqu.Ex
+		}{
+			{blockRaw, byChannel},
+			{peerChannel, byChannel},
+			{channelChaincode, byChannel},
+			{channel, goqu.Ex{"name": channelName}},
+		} {
+			_, err = db.Delete(d.table).
+				Where(d.where).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				tb.Errorf("delete channel `%s` from %s: %v", channelName,
+					d.table, err)
+				return
+			}
+		}
+	})
+
+	return channelName
+}
+
+// dbStates returns states of database. Values are stored as hex text, so
+// they are decoded.
+func dbStates(db *goqu.Database) storagetest.StatesFunc {
+	return func(t *testing.T, channelName, key string) (
+		*storagetest.State, []storagetest.State) {
+
+		t.Helper()
+
+		byKey := goqu.Ex{
+			"c.name":      channelName,
+			"s.chaincode": storagetest.Chaincode,
+			"s.key":       key,
+		}
+
+		var actual, old []storagetest.State
+
+		err := db.From(goqu.I(state).As("s")).
+			Join(goqu.I(channel).As("c"),
+				goqu.On(goqu.Ex{"s.channel_id": goqu.I("c.id")})).
+			Select("s.raw_value", "s.version_block_number",
+				"s.version_tx_number").
+			Where(byKey).
+			ScanStructs(&actual)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.From(goqu.I(oldState).As("s")).
+			Join(goqu.I(channel).As("c"),
+				goqu.On(goqu.Ex{"s.channel_id": goqu.I("c.id")})).
+			Select("s.raw_value", "s.deleted", "s.version_block_number",
+				"s.version_tx_number").
+			Where(byKey).
+			Order(goqu.I("s.id").Asc()).
+			ScanStructs(&old)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, ss := range [][]storagetest.State{actual, old} {
+			for i := range ss {
+				ss[i].Value = decodeHex(t, ss[i].Value)
+			}
+		}
+
+		if len(actual) == 0 {
+			return nil, old
+		}
+
+		return &actual[0], old
+	}
+}
+
+func decodeHex(t *testing.T, h []byte) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(string(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func testStorage(t *testing.T) (hf.Storage, *goqu.Database) {
+	t.Helper()
+
+	db := testDB(t)
+
+	return backend{}.Storage(db, logrus.WithField("test", t.Name())), db
+}
+
+func TestStorageRetriedBlockStates(t *testing.T) {
+	s, db := testStorage(t)
+	storagetest.TestRetriedBlockStates(t, s, testChannel(t, db, "test"),
+		dbStates(db))
+}
+
+func TestStorageRawBlocks(t *testing.T) {
+	s, db := testStorage(t)
+	storagetest.TestRawBlocks(t, s, testChannel(t, db, "test"))
+}
+
+func TestStorageResetChannel(t *testing.T) {
+	s, db := testStorage(t)
+	storagetest.TestResetChannel(t, s, testChannel(t, db, "test"),
+		dbStates(db))
+}
@@ -0,0 +1,237 @@
+package pg
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"explorer"
+)
+
+// Records of entities contain all table columns, so they can be inserted
+// with multi-row inserts and COPY.
+
+// nullJSON returns nil for empty JSON so it is stored as NULL.
+func nullJSON(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+func channelOrganizationRecord(o *explorer.ChannelOrganization) goqu.Record {
+	return goqu.Record{
+		"channel_config_id":  o.ChannelConfigId,
+		"type":               o.Type,
+		"name":               o.Name,
+		"msp_id":             o.MspId,
+		"root_certs":         textArray(o.RootCerts),
+		"intermediate_certs": textArray(o.IntermediateCerts),
+		"tls_root_certs":     textArray(o.TlsRootCerts),
+		"anchor_peers":       textArray(o.AnchorPeers),
+		"orderer_endpoints":  textArray(o.OrdererEndpoints),
+	}
+}
+
+func channelPolicyRecord(cp *explorer.ChannelPolicy) goqu.Record {
+	return goqu.Record{
+		"channel_config_id": cp.ChannelConfigId,
+		"path":              cp.Path,
+		"name":              cp.Name,
+		"type":              cp.Type,
+		"rule":              cp.Rule,
+		"mod_policy":        cp.ModPolicy,
+	}
+}
+
+func rawBlockRecord(rb *explorer.RawBlock) goqu.Record {
+	return goqu.Record{
+		"channel_id":  rb.ChannelId,
+		"number":      rb.Number,
+		"compression": rb.Compression,
+		"source_url":  rb.SourceUrl,
+		"data": goqu.L("decode(?, 'hex')",
+			hex.EncodeToString(rb.Data)),
+	}
+}
+
+func identityRecord(i *explorer.Identity) goqu.Record {
+	r := goqu.Record{
+		"msp_id":        i.MspId,
+		"fingerprint":   i.Fingerprint,
+		"subject":       i.Subject,
+		"issuer":        i.Issuer,
+		"serial_number": i.SerialNumber,
+		"not_after":     nil,
+	}
+	if i.NotAfter != nil {
+		r["not_after"] = i.NotAfter.AsTime()
+	}
+	return r
+}
+
+func transactionRecord(t *explorer.Transaction) goqu.Record {
+	return goqu.Record{
+		"id":              t.Id,
+		"channel_id":      t.ChannelId,
+		"block_id":        t.BlockId,
+		"number":          t.Number,
+		"created_at":      t.CreatedAt.AsTime(),
+		"validation_code": t.ValidationCode,
+		"creator_id":      nullID(t.CreatorId),
+		"function":        t.Function,
+		"type":            t.Type,
+		"raw_payload":     nullBytes(t.RawPayload),
+	}
+}
+
+func argumentRecord(a *explorer.Argument) goqu.Record {
+	return goqu.Record{
+		"transaction_id":     a.TransactionId,
+		"block_id":           a.BlockId,
+		"transaction_number": a.TransactionNumber,
+		"index":              a.Index,
+		"type":               a.Type,
+		"raw_value":          hex.EncodeToString(a.RawValue),
+		"value":              nullJSON(a.Value),
+	}
+}
+
+func endorsementRecord(en *explorer.Endorsement) goqu.Record {
+	return goqu.Record{
+		"transaction_id":     en.TransactionId,
+		"block_id":           en.BlockId,
+		"transaction_number": en.TransactionNumber,
+		"msp_id":             en.MspId,
+		"subject":            en.Subject,
+		"fingerprint":        en.Fingerprint,
+	}
+}
+
+func rangeQueryRecord(rq *explorer.RangeQuery) goqu.Record {
+	return goqu.Record{
+		"id":                 rq.Id,
+		"transaction_id":     rq.TransactionId,
+		"block_id":           rq.BlockId,
+		"transaction_number": rq.TransactionNumber,
+		"chaincode":          rq.Chaincode,
+		"start_key":          rq.StartKey,
+		"end_key":            rq.EndKey,
+		"itr_exhausted":      rq.ItrExhausted,
+	}
+}
+
+func readRecord(r *explorer.Read) goqu.Record {
+	return goqu.Record{
+		"transaction_id":       r.TransactionId,
+		"block_id":             r.BlockId,
+		"transaction_number":   r.TransactionNumber,
+		"range_query_id":       nullID(r.RangeQueryId),
+		"chaincode":            r.Chaincode,
+		"key":                  r.Key,
+		"has_version":          r.HasVersion,
+		"version_block_number": r.VersionBlockNumber,
+		"version_tx_number":    r.VersionTxNumber,
+	}
+}
+
+func privateWriteHashRecord(pwh *explorer.PrivateWriteHash) goqu.Record {
+	return goqu.Record{
+		"channel_id":     pwh.ChannelId,
+		"transaction_id": pwh.TransactionId,
+		"chaincode":      pwh.Chaincode,
+		"collection":     pwh.Collection,
+		"key_hash":       hex.EncodeToString(pwh.KeyHash),
+		"value_hash":     hex.EncodeToString(pwh.ValueHash),
+		"is_delete":      pwh.IsDelete,
+		"created_at":     pwh.CreatedAt.AsTime(),
+	}
+}
+
+func privateWriteRecord(pw *explorer.PrivateWrite) goqu.Record {
+	return goqu.Record{
+		"channel_id":     pw.ChannelId,
+		"transaction_id": pw.TransactionId,
+		"chaincode":      pw.Chaincode,
+		"collection":     pw.Collection,
+		"key":            pw.Key,
+		"type":           pw.Type,
+		"raw_value":      hex.EncodeToString(pw.RawValue),
+		"value":          nullJSON(pw.Value),
+		"is_delete":      pw.IsDelete,
+		"created_at":     pw.CreatedAt.AsTime(),
+	}
+}
+
+func chaincodeEventRecord(ce *explorer.ChaincodeEvent) goqu.Record {
+	return goqu.Record{
+		"channel_id":     ce.ChannelId,
+		"transaction_id": ce.TransactionId,
+		"chaincode":      ce.Chaincode,
+		"name":           ce.Name,
+		"type":           ce.Type,
+		"raw_payload":    hex.EncodeToString(ce.RawPayload),
+		"payload":        nullJSON(ce.Payload),
+		"created_at":     ce.CreatedAt.AsTime(),
+	}
+}
+
+func chaincodeApprovalRecord(ca *explorer.ChaincodeApproval) goqu.Record {
+	return goqu.Record{
+		"chaincode_definition_id": ca.ChaincodeDefinitionId,
+		"transaction_id":          ca.TransactionId,
+		"msp_id":                  ca.MspId,
+		"created_at":              ca.CreatedAt.AsTime(),
+	}
+}
+
+func stateRecord(s *explorer.State) goqu.Record {
+	return goqu.Record{
+		"key":            s.Key,
+		"chaincode":      s.Chaincode,
+		"channel_id":     s.ChannelId,
+		"transaction_id": s.TransactionId,
+		"type":           s.Type,
+		"raw_value":      hex.EncodeToString(s.RawValue),
+		"value":          nullJSON(s.Value),
+		"created_at":     s.CreatedAt.AsTime(),
+
+		"version_block_number": s.VersionBlockNumber,
+		"version_tx_number":    s.VersionTxNumber,
+	}
+}
+
+func oldStateRecord(os *explorer.OldState) goqu.Record {
+	return goqu.Record{
+		"key":            os.Key,
+		"chaincode":      os.Chaincode,
+		"channel_id":     os.ChannelId,
+		"transaction_id": os.TransactionId,
+		"type":           os.Type,
+		"raw_value":      hex.EncodeToString(os.RawValue),
+		"value":          nullJSON(os.Value),
+		"created_at":     os.CreatedAt.AsTime(),
+		"deleted":        os.Deleted,
+
+		"version_block_number": os.VersionBlockNumber,
+		"version_tx_number":    os.VersionTxNumber,
+	}
+}
+
+// stateColumns are columns of state copied to old state when state changes.
+var stateColumns = []interface{}{"key", "chaincode", "channel_id",
+	"transaction_id", "type", "raw_value", "value", "created_at",
+	"version_block_number", "version_tx_number"}
+
+// moveStatesTx copies actual states matching the expression to old states
+// as is, so stored values are not decoded and encoded again.
+func moveStatesTx(ctx context.Context, txx *goqu.TxDatabase,
+	where goqu.Expression) error {
+
+	_, err := txx.Insert(oldState).
+		Cols(stateColumns...).
+		FromQuery(txx.From(state).Select(stateColumns...).Where(where)).
+		Executor().ExecContext(ctx)
+	return err
+}
@@ -0,0 +1 @@
+drop table checkpoint;
\ No newline at end of file
@@ -0,0 +1,9 @@
+create table checkpoint (
+    channel_id bigint primary key references channel(id),
+    block_number bigint not null
+);
+
+insert into checkpoint (channel_id, block_number)
+select channel_id, max(number)
+from block
+group by channel_id;
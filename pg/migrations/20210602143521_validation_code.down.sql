@@ -0,0 +1 @@
+alter table transaction drop column validation_code;
\ No newline at end of file
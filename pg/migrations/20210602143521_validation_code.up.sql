@@ -0,0 +1,4 @@
+alter table transaction add column validation_code text not null default 'VALID';
+alter table transaction alter column validation_code drop default;
+
+create index on transaction (validation_code);
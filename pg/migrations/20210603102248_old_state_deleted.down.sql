@@ -0,0 +1 @@
+alter table old_state drop column deleted;
\ No newline at end of file
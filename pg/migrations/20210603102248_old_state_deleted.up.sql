@@ -0,0 +1 @@
+alter table old_state add column deleted boolean not null default false;
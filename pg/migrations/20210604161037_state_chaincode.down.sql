@@ -0,0 +1,5 @@
+alter table old_state drop column chaincode;
+
+alter table state drop constraint state_pkey;
+alter table state drop column chaincode;
+alter table state add primary key (key);
@@ -0,0 +1,8 @@
+alter table state add column chaincode text not null default '';
+alter table state alter column chaincode drop default;
+alter table state drop constraint state_pkey;
+alter table state add primary key (channel_id, chaincode, key);
+
+alter table old_state add column chaincode text not null default '';
+alter table old_state alter column chaincode drop default;
+create index on old_state (channel_id, chaincode, key);
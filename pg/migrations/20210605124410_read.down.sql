@@ -0,0 +1,2 @@
+drop table read;
+drop table range_query;
\ No newline at end of file
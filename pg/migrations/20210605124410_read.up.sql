@@ -0,0 +1,23 @@
+create table range_query (
+    id bigserial primary key,
+    transaction_id char(65) not null references transaction(id),
+    chaincode text not null,
+    start_key text not null,
+    end_key text not null,
+    itr_exhausted boolean not null
+);
+
+create index on range_query (transaction_id);
+
+create table read (
+    id bigserial primary key,
+    transaction_id char(65) not null references transaction(id),
+    range_query_id bigint references range_query(id),
+    chaincode text not null,
+    key text not null,
+    has_version boolean not null,
+    version_block_number bigint not null,
+    version_tx_number bigint not null
+);
+
+create index on read (transaction_id);
@@ -0,0 +1,2 @@
+drop table private_write;
+drop table private_write_hash;
\ No newline at end of file
@@ -0,0 +1,31 @@
+create table private_write_hash (
+    id bigserial primary key,
+    channel_id bigint not null references channel(id),
+    transaction_id char(65) not null references transaction(id),
+    chaincode text not null,
+    collection text not null,
+    key_hash bytea not null,
+    value_hash bytea not null,
+    is_delete boolean not null,
+    created_at timestamp with time zone not null
+);
+
+create index on private_write_hash (transaction_id);
+create index on private_write_hash (channel_id, chaincode, collection);
+
+create table private_write (
+    id bigserial primary key,
+    channel_id bigint not null references channel(id),
+    transaction_id char(65) not null references transaction(id),
+    chaincode text not null,
+    collection text not null,
+    key text not null,
+    type text not null,
+    raw_value bytea not null,
+    value jsonb,
+    is_delete boolean not null,
+    created_at timestamp with time zone not null
+);
+
+create index on private_write (transaction_id);
+create index on private_write (channel_id, chaincode, collection, key);
@@ -0,0 +1 @@
+drop table chaincode_event;
\ No newline at end of file
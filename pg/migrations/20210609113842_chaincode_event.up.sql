@@ -0,0 +1,14 @@
+create table chaincode_event (
+    id bigserial primary key,
+    channel_id bigint not null references channel(id),
+    transaction_id char(65) not null references transaction(id),
+    chaincode text not null,
+    name text not null,
+    type text not null,
+    raw_payload bytea not null,
+    payload jsonb,
+    created_at timestamp with time zone not null
+);
+
+create index on chaincode_event (transaction_id);
+create index on chaincode_event (channel_id, chaincode, name, created_at);
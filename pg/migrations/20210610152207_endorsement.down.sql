@@ -0,0 +1 @@
+drop table endorsement;
\ No newline at end of file
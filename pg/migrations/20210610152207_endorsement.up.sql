@@ -0,0 +1,10 @@
+create table endorsement (
+    id bigserial primary key,
+    transaction_id char(65) not null references transaction(id),
+    msp_id text not null,
+    subject text not null,
+    fingerprint text not null
+);
+
+create index on endorsement (transaction_id);
+create index on endorsement (msp_id);
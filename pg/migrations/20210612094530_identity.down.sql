@@ -0,0 +1,2 @@
+alter table transaction drop column creator_id;
+drop table identity;
\ No newline at end of file
@@ -0,0 +1,15 @@
+create table identity (
+    id bigserial primary key,
+    msp_id text not null,
+    fingerprint text not null unique,
+    subject text not null,
+    issuer text not null,
+    serial_number text not null,
+    not_after timestamp with time zone
+);
+
+create index on identity (msp_id);
+
+alter table transaction add column creator_id bigint references identity(id);
+
+create index on transaction (creator_id);
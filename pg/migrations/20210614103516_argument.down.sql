@@ -0,0 +1,3 @@
+drop table argument;
+
+alter table transaction drop column function;
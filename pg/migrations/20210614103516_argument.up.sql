@@ -0,0 +1,14 @@
+alter table transaction add column function text not null default '';
+
+create index on transaction (function);
+
+create table argument (
+    id bigserial primary key,
+    transaction_id char(65) not null references transaction(id),
+    index int not null,
+    type text not null,
+    raw_value bytea not null,
+    value jsonb
+);
+
+create index on argument (transaction_id);
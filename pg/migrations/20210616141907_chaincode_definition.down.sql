@@ -0,0 +1,2 @@
+drop table chaincode_approval;
+drop table chaincode_definition;
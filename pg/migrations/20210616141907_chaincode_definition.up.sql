@@ -0,0 +1,25 @@
+create table chaincode_definition (
+    id bigserial primary key,
+    channel_id bigint not null references channel(id),
+    name text not null,
+    sequence bigint not null,
+    version text not null,
+    endorsement_plugin text not null,
+    validation_plugin text not null,
+    endorsement_policy jsonb,
+    collections jsonb,
+    init_required boolean not null,
+    transaction_id char(65) references transaction(id),
+    committed_at timestamp with time zone,
+    unique (channel_id, name, sequence)
+);
+
+create table chaincode_approval (
+    id bigserial primary key,
+    chaincode_definition_id bigint not null references chaincode_definition(id),
+    transaction_id char(65) not null references transaction(id),
+    msp_id text not null,
+    created_at timestamp with time zone not null
+);
+
+create index on chaincode_approval (chaincode_definition_id);
@@ -0,0 +1,11 @@
+drop table channel_policy;
+drop table channel_organization;
+
+alter table channel_config
+    drop column sequence,
+    drop column consensus_type,
+    drop column batch_max_message_count,
+    drop column batch_absolute_max_bytes,
+    drop column batch_preferred_max_bytes,
+    drop column batch_timeout,
+    drop column orderer_addresses;
@@ -0,0 +1,43 @@
+alter table channel_config
+    add column sequence bigint not null default 0,
+    add column consensus_type text not null default '',
+    add column batch_max_message_count bigint not null default 0,
+    add column batch_absolute_max_bytes bigint not null default 0,
+    add column batch_preferred_max_bytes bigint not null default 0,
+    add column batch_timeout text not null default '',
+    add column orderer_addresses text[] not null default '{}';
+
+-- Sequences of configs stored before this migration are restored from their
+-- parsed JSON. Their organizations, orderer settings and policies can't be
+-- decoded in SQL, channels indexed before this migration have to be
+-- reindexed, see CHANGELOG.md.
+update channel_config
+set sequence = coalesce((parsed ->> 'sequence')::bigint, 0);
+
+create table channel_organization (
+    id bigserial primary key,
+    channel_config_id bigint not null references channel_config(id),
+    type text not null,
+    name text not null,
+    msp_id text not null,
+    root_certs text[] not null,
+    intermediate_certs text[] not null,
+    tls_root_certs text[] not null,
+    anchor_peers text[] not null,
+    orderer_endpoints text[] not null
+);
+
+create index on channel_organization (channel_config_id);
+create index on channel_organization (msp_id);
+
+create table channel_policy (
+    id bigserial primary key,
+    channel_config_id bigint not null references channel_config(id),
+    path text not null,
+    name text not null,
+    type text not null,
+    rule text not null,
+    mod_policy text not null
+);
+
+create index on channel_policy (channel_config_id, path);
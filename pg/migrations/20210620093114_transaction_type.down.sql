@@ -0,0 +1,3 @@
+alter table transaction
+    drop column type,
+    drop column raw_payload;
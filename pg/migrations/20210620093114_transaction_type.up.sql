@@ -0,0 +1,5 @@
+alter table transaction
+    add column type text not null default '',
+    add column raw_payload bytea;
+
+create index on transaction (type);
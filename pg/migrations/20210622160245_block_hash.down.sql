@@ -0,0 +1,6 @@
+alter table block
+    drop column data_hash,
+    drop column previous_hash,
+    drop column hash,
+    drop column transaction_count,
+    drop column size;
@@ -0,0 +1,8 @@
+alter table block
+    add column data_hash text not null default '',
+    add column previous_hash text not null default '',
+    add column hash text not null default '',
+    add column transaction_count bigint not null default 0,
+    add column size bigint not null default 0;
+
+create index on block (hash);
@@ -0,0 +1,5 @@
+drop table block_signature;
+
+alter table block
+    drop column last_config_index,
+    drop column commit_hash;
@@ -0,0 +1,11 @@
+alter table block
+    add column last_config_index bigint not null default 0,
+    add column commit_hash text not null default '';
+
+create table block_signature (
+    block_id bigint not null references block(id),
+    identity_id bigint not null references identity(id),
+    primary key (block_id, identity_id)
+);
+
+create index on block_signature (identity_id);
@@ -0,0 +1 @@
+drop table block_raw;
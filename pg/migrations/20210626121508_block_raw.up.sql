@@ -0,0 +1,8 @@
+create table block_raw (
+    channel_id bigint not null references channel(id),
+    number bigint not null,
+    compression text not null,
+    source_url text not null,
+    data bytea not null,
+    primary key (channel_id, number)
+);
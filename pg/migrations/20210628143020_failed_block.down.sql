@@ -0,0 +1 @@
+drop table failed_block;
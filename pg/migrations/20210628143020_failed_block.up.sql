@@ -0,0 +1,12 @@
+create table failed_block (
+    id bigserial primary key,
+    channel_id bigint not null references channel(id),
+    number bigint not null,
+    compression text not null,
+    source_url text not null,
+    data bytea not null,
+    error text not null,
+    failures integer not null,
+    created_at timestamptz not null default now(),
+    unique (channel_id, number)
+);
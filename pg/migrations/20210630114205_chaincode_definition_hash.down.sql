@@ -0,0 +1,3 @@
+alter table chaincode_definition
+    drop column parameters_hash,
+    add unique (channel_id, name, sequence);
@@ -0,0 +1,4 @@
+alter table chaincode_definition
+    add column parameters_hash text not null default '',
+    drop constraint chaincode_definition_channel_id_name_sequence_key,
+    add unique (channel_id, name, sequence, parameters_hash);
@@ -0,0 +1,55 @@
+alter table argument
+    drop column block_id,
+    drop column transaction_number;
+
+alter table endorsement
+    drop column block_id,
+    drop column transaction_number;
+
+alter table read
+    drop column block_id,
+    drop column transaction_number;
+
+alter table range_query
+    drop column block_id,
+    drop column transaction_number;
+
+drop index transaction_id_idx;
+
+alter table transaction
+    drop constraint transaction_pkey,
+    drop column number,
+    add primary key (id);
+
+alter table argument
+    add foreign key (transaction_id) references transaction (id);
+
+alter table endorsement
+    add foreign key (transaction_id) references transaction (id);
+
+alter table read
+    add foreign key (transaction_id) references transaction (id);
+
+alter table range_query
+    add foreign key (transaction_id) references transaction (id);
+
+alter table private_write_hash
+    add foreign key (transaction_id) references transaction (id);
+
+alter table private_write
+    add foreign key (transaction_id) references transaction (id);
+
+alter table chaincode_event
+    add foreign key (transaction_id) references transaction (id);
+
+alter table state
+    add foreign key (transaction_id) references transaction (id);
+
+alter table old_state
+    add foreign key (transaction_id) references transaction (id);
+
+alter table chaincode_definition
+    add foreign key (transaction_id) references transaction (id);
+
+alter table chaincode_approval
+    add foreign key (transaction_id) references transaction (id);
@@ -0,0 +1,89 @@
+alter table transaction add column number integer;
+
+-- Indexes in blocks of transactions stored before this migration are unknown.
+-- They get negative numbers -1, -2 and so on, which identify them in their
+-- blocks, but are not their indexes, until channel is reindexed, see
+-- CHANGELOG.md. Transaction IDs were unique before this migration, so rows
+-- referencing transactions get exactly their numbers.
+update transaction t
+set number = n.number
+from (
+    select id, -row_number() over (partition by block_id order by id) as number
+    from transaction
+) n
+where t.id = n.id;
+
+alter table transaction
+    alter column number set not null,
+    drop constraint transaction_pkey cascade,
+    add primary key (block_id, number);
+
+create index on transaction (id);
+
+alter table argument
+    add column block_id bigint,
+    add column transaction_number integer;
+
+update argument x
+set block_id = t.block_id, transaction_number = t.number
+from transaction t
+where t.id = x.transaction_id;
+
+alter table argument
+    alter column block_id set not null,
+    alter column transaction_number set not null,
+    add foreign key (block_id, transaction_number)
+        references transaction (block_id, number);
+
+create index on argument (block_id, transaction_number);
+
+alter table endorsement
+    add column block_id bigint,
+    add column transaction_number integer;
+
+update endorsement x
+set block_id = t.block_id, transaction_number = t.number
+from transaction t
+where t.id = x.transaction_id;
+
+alter table endorsement
+    alter column block_id set not null,
+    alter column transaction_number set not null,
+    add foreign key (block_id, transaction_number)
+        references transaction (block_id, number);
+
+create index on endorsement (block_id, transaction_number);
+
+alter table read
+    add column block_id bigint,
+    add column transaction_number integer;
+
+update read x
+set block_id = t.block_id, transaction_number = t.number
+from transaction t
+where t.id = x.transaction_id;
+
+alter table read
+    alter column block_id set not null,
+    alter column transaction_number set not null,
+    add foreign key (block_id, transaction_number)
+        references transaction (block_id, number);
+
+create index on read (block_id, transaction_number);
+
+alter table range_query
+    add column block_id bigint,
+    add column transaction_number integer;
+
+update range_query x
+set block_id = t.block_id, transaction_number = t.number
+from transaction t
+where t.id = x.transaction_id;
+
+alter table range_query
+    alter column block_id set not null,
+    alter column transaction_number set not null,
+    add foreign key (block_id, transaction_number)
+        references transaction (block_id, number);
+
+create index on range_query (block_id, transaction_number);
@@ -0,0 +1,7 @@
+alter table old_state
+    drop column version_block_number,
+    drop column version_tx_number;
+
+alter table state
+    drop column version_block_number,
+    drop column version_tx_number;
@@ -0,0 +1,42 @@
+-- Versions of stored states are unknown, they are -1 unless restored below.
+-- State of unknown version is replaced by any write, including write of
+-- failed block retried after later blocks, until channel is reindexed, see
+-- CHANGELOG.md.
+alter table state
+    add column version_block_number bigint not null default -1,
+    add column version_tx_number bigint not null default -1;
+
+alter table old_state
+    add column version_block_number bigint not null default -1,
+    add column version_tx_number bigint not null default -1;
+
+-- Only a valid transaction writes states and a transaction ID is valid once
+-- in a channel, so it identifies the block of the write. Negative numbers of
+-- transactions stored before 20210630152140_transaction_key are not their
+-- indexes, so transaction number of version stays unknown for them.
+update state s
+set version_block_number = b.number,
+    version_tx_number = greatest(t.number, -1)
+from transaction t
+join block b on b.id = t.block_id
+where t.id = s.transaction_id and t.channel_id = s.channel_id
+    and t.validation_code = 'VALID';
+
+update old_state s
+set version_block_number = b.number,
+    version_tx_number = greatest(t.number, -1)
+from transaction t
+join block b on b.id = t.block_id
+where t.id = s.transaction_id and t.channel_id = s.channel_id
+    and t.validation_code = 'VALID';
+
+alter table state
+    alter column version_block_number drop default,
+    alter column version_tx_number drop default;
+
+alter table old_state
+    alter column version_block_number drop default,
+    alter column version_tx_number drop default;
+
+create index on old_state (channel_id, chaincode, key,
+    version_block_number, version_tx_number) where deleted;
@@ -0,0 +1,411 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/gorilla/mux"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_logrus "github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/rs/cors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v2"
+
+	"explorer"
+	"explorer/hf"
+	"explorer/ui"
+)
+
+const (
+	peer             = "peer"
+	channel          = "channel"
+	peerChannel      = "peer_channel"
+	channelConfig    = "channel_config"
+	chaincode        = "chaincode"
+	channelChaincode = "channel_chaincode"
+	block            = "block"
+	blockSignature   = "block_signature"
+	transaction      = "transaction"
+	identity         = "identity"
+	argument         = "argument"
+	endorsement      = "endorsement"
+	rangeQuery       = "range_query"
+	read             = "read"
+	privateWriteHash = "private_write_hash"
+	privateWrite     = "private_write"
+	chaincodeEvent   = "chaincode_event"
+	state            = "state"
+	oldState         = "old_state"
+
+	chaincodeDefinition = "chaincode_definition"
+	chaincodeApproval   = "chaincode_approval"
+	channelOrganization = "channel_organization"
+	channelPolicy       = "channel_policy"
+	failedBlock         = "failed_block"
+)
+
+type Config struct {
+	Listen struct {
+		GRPC string `yaml:"grpc"`
+		HTTP string `yaml:"http"`
+	} `yaml:"listen"`
+	DSN              string               `yaml:"dsn"`
+	PrivateDataToken string               `yaml:"private_data_token"`
+	Processors       []hf.ProcessorConfig `yaml:"processors"`
+
+	// ValueTypes are types of chaincode state values registered in addition
+	// to value types registered in code.
+	ValueTypes []hf.ValueTypeConfig `yaml:"value_types"`
+}
+
+// Backend is a SQL database of explorer. It stores processed blocks and
+// adapts queries of explorer service to its SQL dialect.
+type Backend interface {
+	// Name is a name of backend used in logs.
+	Name() string
+
+	// Driver and Dialect are names of database/sql driver and goqu dialect
+	// of database.
+	Driver() string
+	Dialect() string
+
+	// Migrate migrates database, migration driver takes ownership of sqlDB.
+	Migrate(sqlDB *sql.DB) error
+	Storage(db *goqu.Database, log *logrus.Entry) hf.Storage
+
+	// Array returns scan destination of text array column.
+	Array(a *[]string) interface{}
+
+	// ArrayOf returns text array of values of query, which selects single
+	// column named `value`.
+	ArrayOf(query string) exp.LiteralExpression
+}
+
+type Explorer struct {
+	config      Config
+	backend     Backend
+	sqlDB       *sql.DB
+	db          *goqu.Database
+	processors  []*hf.Processor
+	tcpListener net.Listener
+	grpcServer  *grpc.Server
+	httpServer  *http.Server
+	log         *logrus.Entry
+
+	explorer.UnimplementedExplorerServer
+}
+
+func NewExplorer(c Config, b Backend) (*Explorer, error) {
+	return &Explorer{
+		config:  c,
+		backend: b,
+		log: logrus.WithFields(logrus.Fields{
+			"subsystem": b.Name() + "_explorer",
+		}),
+	}, nil
+}
+
+func (e *Explorer) Migrate() error {
+
+	sqlDB, err := sql.Open(e.backend.Driver(), e.config.DSN)
+	if err != nil {
+		return fmt.Errorf("open DB: %w", err)
+	}
+
+	defer func() {
+		err := sqlDB.Close()
+		if err != nil {
+			e.log.WithError(err).Error("failed to close DB")
+		}
+	}()
+
+	return e.backend.Migrate(sqlDB)
+}
+
+func (e *Explorer) Run() (err error) {
+
+	err = hf.RegisterValueTypes(e.config.ValueTypes)
+	if err != nil {
+		return fmt.Errorf("register value types: %w", err)
+	}
+
+	e.sqlDB, err = sql.Open(e.backend.Driver(), e.config.DSN)
+	if err != nil {
+		return fmt.Errorf("open DB: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err := e.sqlDB.Close()
+			if err != nil {
+				e.log.WithError(err).Error("failed to close DB")
+			}
+		}
+	}()
+
+	e.db = goqu.New(e.backend.Dialect(), e.sqlDB)
+
+	// Connection is kept open during migration, since shared in-memory
+	// database exists while it has connections.
+	err = e.sqlDB.Ping()
+	if err != nil {
+		return fmt.Errorf("connect DB: %w", err)
+	}
+
+	err = e.Migrate()
+	if err != nil {
+		return fmt.Errorf("migrate DB: %w", err)
+	}
+
+	storage := e.backend.Storage(e.db, e.log)
+
+	for _, pc := range e.config.Processors {
+
+		e.log.WithField("channel_id", pc.ChannelName).
+			Info("creating and starting processor")
+
+		p, pErr := hf.NewProcessor(pc, storage)
+		if pErr != nil {
+			return fmt.Errorf(
+				"create processor for channel_id=`%s`: %w",
+				pc.ChannelName, pErr)
+		}
+
+		defer func(p *hf.Processor) {
+			if err != nil {
+				p.Close()
+			}
+		}(p)
+
+		e.processors = append(e.processors, p)
+	}
+
+	e.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(
+		grpc_middleware.ChainUnaryServer(
+			grpc_logrus.UnaryServerInterceptor(e.log),
+			e.UnaryAuthInterceptor,
+		),
+	))
+
+	explorer.RegisterExplorerServer(e.grpcServer, e)
+
+	e.tcpListener, err = net.Listen("tcp", e.config.Listen.GRPC)
+	if err != nil {
+		return fmt.Errorf("create TCP listener: %w", err)
+	}
+
+	go func() {
+		for {
+			err := e.grpcServer.Serve(e.tcpListener)
+			if err != nil {
+				e.log.WithError(err).Error("failed to start GRPC server")
+			}
+			time.Sleep(3 * time.Second)
+		}
+	}()
+
+	explorerAPIMux := runtime.NewServeMux()
+
+	err = explorer.RegisterExplorerHandlerFromEndpoint(
+		context.Background(), explorerAPIMux, e.tcpListener.Addr().String(),
+		[]grpc.DialOption{grpc.WithInsecure()})
+	if err != nil {
+		return fmt.Errorf("failed to init serve mux: %w", err)
+	}
+
+	httpRouter := mux.NewRouter()
+
+	httpRouter.PathPrefix("/api/").Handler(
+		cors.Default().Handler(explorerAPIMux))
+
+	swaggerFile, err := explorer.FS.Open(explorer.SwaggerFile)
+	if err != nil {
+		return fmt.Errorf("failed to open swagger file: %w", err)
+	}
+
+	swaggerJSON, err := ioutil.ReadAll(swaggerFile)
+	swaggerFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read swagger file: %w", err)
+	}
+
+	httpRouter.Path("/swagger.json").Methods(http.MethodGet).
+		HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write(swaggerJSON)
+		})
+
+	indexFile, err := uiFS.Open(path.Join(ui.Prefix, "index.html"))
+	if err != nil {
+		return fmt.Errorf("failed to open index file: %w", err)
+	}
+
+	indexHTML, err := ioutil.ReadAll(indexFile)
+	indexFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read index file: %w", err)
+	}
+
+	httpRouter.PathPrefix("/").HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+
+			staticPath := path.Join(ui.Prefix, r.URL.Path)
+
+			content, err := uiFS.ReadFile(staticPath)
+			if err != nil {
+				w.Write(indexHTML)
+				return
+			}
+
+			w.Header().Set("Content-Type",
+				mime.TypeByExtension(path.Ext(staticPath)))
+			w.Write(content)
+		})
+
+	e.httpServer = &http.Server{
+		Addr:    e.config.Listen.HTTP,
+		Handler: httpRouter,
+	}
+
+	go func() {
+		for {
+			err := e.httpServer.ListenAndServe()
+			if err != nil {
+				if err == http.ErrServerClosed {
+					break
+				}
+				e.log.WithError(err).Error("failed to start HTTP server")
+			}
+			time.Sleep(3 * time.Second)
+		}
+	}()
+
+	return nil
+}
+
+func (e *Explorer) Close() {
+
+	var wg sync.WaitGroup
+
+	for _, p := range e.processors {
+		wg.Add(1)
+		go func(p *hf.Processor) {
+			defer wg.Done()
+			p.Close()
+		}(p)
+	}
+
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 9*time.Second)
+	defer cancel()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := e.httpServer.Shutdown(ctx)
+		if err != nil {
+			e.log.WithError(err).Error("failed to stop HTTP server")
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.grpcServer.GracefulStop()
+	}()
+
+	wg.Wait()
+
+	err := e.tcpListener.Close()
+	if err != nil {
+		e.log.WithError(err).Error("failed to close TCP listener")
+	}
+
+	err = e.sqlDB.Close()
+	if err != nil {
+		e.log.WithError(err).Error("failed to close DB connection")
+	}
+}
+
+// RunExplorer runs explorer with the given backend and config from
+// EXPLORER_CONFIG file until exit signal. With `verify` argument it verifies
+// blocks hash chain and exits.
+func RunExplorer(b Backend) {
+	logrus.SetLevel(logrus.DebugLevel)
+	logrus.SetFormatter(&logrus.TextFormatter{
+		ForceColors: true,
+	})
+
+	log := logrus.WithField("subsystem", "main")
+
+	configPath := os.Getenv("EXPLORER_CONFIG")
+	if configPath == "" {
+		configPath = "config.yaml"
+	}
+
+	log.WithField("config_path", configPath).Info("loading config")
+
+	configYAML, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		log.WithError(err).Fatal("failed to read config")
+	}
+
+	var c Config
+
+	err = yaml.Unmarshal(configYAML, &c)
+	if err != nil {
+		log.WithError(err).Fatal("failed to parse config")
+	}
+
+	log.Info("creating and starting explorer")
+
+	e, err := NewExplorer(c, b)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create explorer")
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		log.Info("verifying blocks hash chain")
+
+		err = e.Verify()
+		if err != nil {
+			log.WithError(err).Fatal("failed to verify blocks")
+		}
+
+		log.Info("blocks verified, exiting")
+		return
+	}
+
+	err = e.Run()
+	if err != nil {
+		log.WithError(err).Fatal("failed to run explorer")
+	}
+
+	log.Info("explorer started")
+
+	exit := make(chan os.Signal, 1)
+	signal.Notify(exit, os.Interrupt, syscall.SIGTERM)
+
+	log.WithField("signal", <-exit).Info("exit signal received")
+
+	log.Info("closing explorer")
+
+	e.Close()
+
+	log.Info("explorer closed, exiting")
+}
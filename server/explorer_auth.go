@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// privateDataMethods are methods which return cleartext private data. They
+// are allowed only with `private_data_token` from config.
+var privateDataMethods = map[string]bool{
+	"/ent.Explorer/GetPrivateWrites": true,
+}
+
+func (e *Explorer) UnaryAuthInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+
+	if privateDataMethods[info.FullMethod] {
+		err := e.authorizePrivateData(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// TODO
+
+	return handler(ctx, req)
+}
+
+func (e *Explorer) authorizePrivateData(ctx context.Context) error {
+
+	if e.config.PrivateDataToken == "" {
+		return status.Error(codes.PermissionDenied,
+			"private data access is disabled")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, a := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(a),
+			[]byte("Bearer "+e.config.PrivateDataToken)) == 1 {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated,
+		"valid private data token required")
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"explorer"
+)
+
+const (
+	configAdded    = "added"
+	configRemoved  = "removed"
+	configModified = "modified"
+)
+
+func (e *Explorer) GetChannelConfigDiff(ctx context.Context,
+	req *explorer.GetChannelConfigDiffReq) (
+	*explorer.GetChannelConfigDiffRes, error) {
+
+	if req.ChannelConfigId == 0 {
+		return nil, status.Error(codes.InvalidArgument,
+			"channel_config_id required")
+	}
+
+	to, err := e.flatChannelConfig(ctx, req.ChannelConfigId)
+	if err != nil {
+		return nil, err
+	}
+	if !to.decoded {
+		return nil, notDecodedConfigError(req.ChannelConfigId)
+	}
+
+	fromID := req.FromChannelConfigId
+
+	// Previous config is the config of the greatest lower sequence, since
+	// config of failed block retried later has greater ID than next configs.
+	if fromID == 0 {
+		_, err = e.db.From(channelConfig).
+			Select("id").
+			Where(goqu.Ex{
+				"channel_id": to.channelID,
+				"sequence":   goqu.Op{"lt": to.sequence},
+			}).
+			Order(goqu.I("sequence").Desc(), goqu.I("id").Desc()).
+			Limit(1).
+			ScanValContext(ctx, &fromID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	from := &flatConfig{values: map[string]string{}}
+
+	if fromID != 0 {
+		from, err = e.flatChannelConfig(ctx, fromID)
+		if err != nil {
+			return nil, err
+		}
+		if from.channelID != to.channelID {
+			return nil, status.Error(codes.InvalidArgument,
+				"channel configs of different channels given")
+		}
+		if !from.decoded {
+			return nil, notDecodedConfigError(fromID)
+		}
+	}
+
+	return &explorer.GetChannelConfigDiffRes{
+		FromChannelConfigId: fromID,
+		ChannelConfigId:     req.ChannelConfigId,
+		Changes:             diffConfigs(from.values, to.values),
+	}, nil
+}
+
+// notDecodedConfigError returns error of config stored before channel
+// configs were decoded, so it has no organizations and policies to diff.
+func notDecodedConfigError(id int64) error {
+	return status.Errorf(codes.FailedPrecondition,
+		"channel config %d is not decoded, channel must be reindexed", id)
+}
+
+// flatConfig is a channel config flattened to path-value pairs. Set members,
+// like anchor peers or certificates, are paths with empty values. Decoded is
+// false for configs stored before channel configs were decoded, which have
+// no organizations, while any decoded config has at least one.
+type flatConfig struct {
+	channelID int64
+	sequence  int64
+	decoded   bool
+	values    map[string]string
+}
+
+func (e *Explorer) flatChannelConfig(ctx context.Context, id int64) (
+	*flatConfig, error) {
+
+	var (
+		cc               = &explorer.ChannelConfig{}
+		ordererAddresses []string
+	)
+
+	rows, err := e.db.From(channelConfig).
+		Select("channel_id", "sequence", "consensus_type",
+			"batch_max_message_count", "batch_absolute_max_bytes",
+			"batch_preferred_max_bytes", "batch_timeout", "orderer_addresses").
+		Where(goqu.Ex{"id": id}).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, status.Error(codes.NotFound, "channel config not found")
+	}
+
+	err = rows.Scan(&cc.ChannelId, &cc.Sequence, &cc.ConsensusType,
+		&cc.BatchMaxMessageCount, &cc.BatchAbsoluteMaxBytes,
+		&cc.BatchPreferredMaxBytes, &cc.BatchTimeout,
+		e.backend.Array(&ordererAddresses))
+	if err != nil {
+		return nil, err
+	}
+
+	fc := &flatConfig{
+		channelID: cc.ChannelId,
+		sequence:  cc.Sequence,
+		values: map[string]string{
+			"orderer/consensus_type": cc.ConsensusType,
+			"orderer/batch_size/max_message_count": strconv.FormatInt(
+				cc.BatchMaxMessageCount, 10),
+			"orderer/batch_size/absolute_max_bytes": strconv.FormatInt(
+				cc.BatchAbsoluteMaxBytes, 10),
+			"orderer/batch_size/preferred_max_bytes": strconv.FormatInt(
+				cc.BatchPreferredMaxBytes, 10),
+			"orderer/batch_timeout": cc.BatchTimeout,
+		},
+	}
+
+	for _, a := range ordererAddresses {
+		fc.values["orderer_addresses/"+a] = ""
+	}
+
+	orgs, err := e.getChannelOrganizations(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+
+	fc.decoded = len(orgs) > 0
+
+	for _, o := range orgs {
+		p := "organizations/" + o.Type + "/" + o.Name
+		fc.values[p] = o.MspId
+		for _, c := range o.RootCerts {
+			fc.values[p+"/root_certs/"+certFingerprint(c)] = ""
+		}
+		for _, c := range o.IntermediateCerts {
+			fc.values[p+"/intermediate_certs/"+certFingerprint(c)] = ""
+		}
+		for _, c := range o.TlsRootCerts {
+			fc.values[p+"/tls_root_certs/"+certFingerprint(c)] = ""
+		}
+		for _, ap := range o.AnchorPeers {
+			fc.values[p+"/anchor_peers/"+ap] = ""
+		}
+		for _, oe := range o.OrdererEndpoints {
+			fc.values[p+"/orderer_endpoints/"+oe] = ""
+		}
+	}
+
+	cps, err := e.getChannelPolicies(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cp := range cps {
+		p := "policies/" + cp.Path + "/" + cp.Name
+		fc.values[p] = cp.Type + " " + cp.Rule
+		fc.values[p+"/mod_policy"] = cp.ModPolicy
+	}
+
+	return fc, nil
+}
+
+func certFingerprint(pem string) string {
+	h := sha256.Sum256([]byte(pem))
+	return hex.EncodeToString(h[:])
+}
+
+// diffConfigs returns changes of flattened configs sorted by path.
+func diffConfigs(from, to map[string]string) []*explorer.ChannelConfigChange {
+	var cs []*explorer.ChannelConfigChange
+
+	for p, v := range to {
+		old, exists := from[p]
+		switch {
+		case !exists:
+			cs = append(cs, &explorer.ChannelConfigChange{
+				Path:     p,
+				Change:   configAdded,
+				NewValue: v,
+			})
+		case old != v:
+			cs = append(cs, &explorer.ChannelConfigChange{
+				Path:     p,
+				Change:   configModified,
+				OldValue: old,
+				NewValue: v,
+			})
+		}
+	}
+
+	for p, v := range from {
+		if _, exists := to[p]; !exists {
+			cs = append(cs, &explorer.ChannelConfigChange{
+				Path:     p,
+				Change:   configRemoved,
+				OldValue: v,
+			})
+		}
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Path < cs[j].Path
+	})
+
+	return cs
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"testing"
+
+	"explorer"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	const org1 = "organizations/application/Org1"
+
+	for _, c := range []struct {
+		name     string
+		from, to map[string]string
+		changes  []*explorer.ChannelConfigChange
+	}{{
+		name: "equal",
+		from: map[string]string{"orderer/batch_timeout": "2s"},
+		to:   map[string]string{"orderer/batch_timeout": "2s"},
+	}, {
+		name: "first config",
+		from: map[string]string{},
+		to: map[string]string{
+			"orderer/consensus_type":                "etcdraft",
+			"organizations/application/Org1":        "Org1MSP",
+			"orderer_addresses/orderer.example.com": "",
+		},
+		changes: []*explorer.ChannelConfigChange{
+			{Path: "orderer/consensus_type", Change: configAdded,
+				NewValue: "etcdraft"},
+			{Path: "orderer_addresses/orderer.example.com",
+				Change: configAdded},
+			{Path: "organizations/application/Org1", Change: configAdded,
+				NewValue: "Org1MSP"},
+		},
+	}, {
+		name: "added, removed and modified",
+		from: map[string]string{
+			"orderer/batch_timeout":    "2s",
+			org1:                       "Org1MSP",
+			org1 + "/anchor_peers/a:1": "",
+			"policies/Channel/Admins":  "IMPLICIT_META MAJORITY Admins",
+		},
+		to: map[string]string{
+			"orderer/batch_timeout":    "1s",
+			org1:                       "Org1MSP",
+			org1 + "/anchor_peers/b:1": "",
+			"policies/Channel/Admins":  "IMPLICIT_META ANY Admins",
+		},
+		changes: []*explorer.ChannelConfigChange{
+			{Path: "orderer/batch_timeout", Change: configModified,
+				OldValue: "2s", NewValue: "1s"},
+			{Path: org1 + "/anchor_peers/a:1", Change: configRemoved},
+			{Path: org1 + "/anchor_peers/b:1", Change: configAdded},
+			{Path: "policies/Channel/Admins", Change: configModified,
+				OldValue: "IMPLICIT_META MAJORITY Admins",
+				NewValue: "IMPLICIT_META ANY Admins"},
+		},
+	}, {
+		name: "removed organization",
+		from: map[string]string{
+			"organizations/application/Org1": "Org1MSP",
+			"organizations/application/Org2": "Org2MSP",
+		},
+		to: map[string]string{
+			"organizations/application/Org1": "Org1MSP",
+		},
+		changes: []*explorer.ChannelConfigChange{
+			{Path: "organizations/application/Org2", Change: configRemoved,
+				OldValue: "Org2MSP"},
+		},
+	}} {
+		changes := diffConfigs(c.from, c.to)
+
+		if len(changes) != len(c.changes) {
+			t.Errorf("%s: got changes %v, want %v", c.name, changes,
+				c.changes)
+			continue
+		}
+
+		for i, got := range changes {
+			want := c.changes[i]
+			if got.Path != want.Path || got.Change != want.Change ||
+				got.OldValue != want.OldValue ||
+				got.NewValue != want.NewValue {
+				t.Errorf("%s: got change %d %v, want %v", c.name, i, got,
+					want)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"explorer"
+	"explorer/hf"
+)
+
+func (e *Explorer) GetFailedBlocks(ctx context.Context,
+	req *explorer.GetFailedBlocksReq) (*explorer.GetFailedBlocksRes, error) {
+
+	where := goqu.Ex{}
+
+	if req.ChannelId != 0 {
+		where["channel_id"] = req.ChannelId
+	}
+
+	if req.FromId != 0 {
+		where["id"] = goqu.Op{"lt": req.FromId}
+	}
+
+	// Block data is not listed, it is used by retry only.
+	rows, err := e.db.From(failedBlock).
+		Select("id", "channel_id", "number", "compression", "source_url",
+			"error", "failures", "created_at").
+		Where(where).
+		OrderAppend(goqu.I("id").Desc()).
+		Limit(defaultLimit).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var fbs []*explorer.FailedBlock
+
+	for rows.Next() {
+		fb := &explorer.FailedBlock{}
+		var createdAt time.Time
+		err = rows.Scan(&fb.Id, &fb.ChannelId, &fb.Number, &fb.Compression,
+			&fb.SourceUrl, &fb.Error, &fb.Failures, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		fb.CreatedAt = timestamppb.New(createdAt)
+		fbs = append(fbs, fb)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetFailedBlocksRes{
+		FailedBlocks: fbs,
+	}, nil
+}
+
+func (e *Explorer) RetryFailedBlock(ctx context.Context,
+	req *explorer.RetryFailedBlockReq) (*explorer.RetryFailedBlockRes, error) {
+
+	if req.Id == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+
+	var (
+		fb          = &explorer.FailedBlock{}
+		channelName string
+	)
+
+	rows, err := e.db.From(goqu.I(failedBlock).As("fb")).
+		Join(goqu.I(channel).As("c"),
+			goqu.On(goqu.Ex{"fb.channel_id": goqu.I("c.id")})).
+		Select("fb.id", "fb.channel_id", "fb.number", "fb.compression",
+			"fb.source_url", "fb.data", "c.name").
+		Where(goqu.Ex{"fb.id": req.Id}).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, status.Error(codes.NotFound, "failed block not found")
+	}
+
+	err = rows.Scan(&fb.Id, &fb.ChannelId, &fb.Number, &fb.Compression,
+		&fb.SourceUrl, &fb.Data, &channelName)
+	if err != nil {
+		return nil, err
+	}
+
+	var p *hf.Processor
+
+	for _, pp := range e.processors {
+		if pp.ChannelName() == channelName {
+			p = pp
+			break
+		}
+	}
+
+	if p == nil {
+		return nil, status.Error(codes.FailedPrecondition,
+			"processor of failed block channel not running")
+	}
+
+	err = p.RetryFailedBlock(ctx, fb)
+	if err != nil {
+		if errors.Is(err, hf.ErrProcessorStopped) {
+			return nil, status.Error(codes.FailedPrecondition,
+				"processor of failed block channel stopped")
+		}
+		return nil, fmt.Errorf("retry failed block: %w", err)
+	}
+
+	return &explorer.RetryFailedBlockRes{}, nil
+}
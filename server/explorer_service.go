@@ -0,0 +1,1153 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"explorer"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+const (
+	defaultLimit = 100
+
+	validTransaction = "VALID"
+)
+
+func (e *Explorer) PostLogin(ctx context.Context, in *explorer.PostLoginReq) (
+	*explorer.PostLoginRes, error) {
+	return nil, fmt.Errorf("TODO")
+}
+
+func (e *Explorer) GetPeers(ctx context.Context, req *explorer.GetPeersReq) (
+	*explorer.GetPeersRes, error) {
+
+	q := e.db.From(goqu.I(peer).As("p")).
+		Select(goqu.I("p.id").As("id"),
+			goqu.I("p.url").As("url")).
+		Order(goqu.I("p.id").Asc())
+
+	if req.ChannelId != 0 {
+		q = q.Join(goqu.I(peerChannel).As("pc"),
+			goqu.On(goqu.Ex{"p.id": goqu.I("pc.peer_id")})).
+			Where(goqu.Ex{"pc.channel_id": req.ChannelId})
+	}
+
+	var ps []*explorer.Peer
+
+	err := q.ScanStructsContext(ctx, &ps)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetPeersRes{
+		Peers: ps,
+	}, nil
+}
+
+func (e *Explorer) GetChannels(ctx context.Context,
+	req *explorer.GetChannelsReq) (
+	*explorer.GetChannelsRes, error) {
+
+	q := e.db.From(goqu.I(channel).As("c")).
+		Select(goqu.I("c").All())
+
+	if req.PeerId != 0 {
+		q = q.Join(goqu.I(peerChannel).As("pc"),
+			goqu.On(goqu.Ex{"c.id": goqu.I("pc.channel_id")})).
+			Where(goqu.Ex{"pc.peer_id": req.PeerId})
+	}
+
+	q = q.OrderAppend(goqu.I("id").Asc())
+
+	var ss []*explorer.Channel
+
+	err := q.Executor().ScanStructsContext(ctx, &ss)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetChannelsRes{
+		Channels: ss,
+	}, nil
+}
+
+func (e *Explorer) GetChannelConfigs(ctx context.Context,
+	req *explorer.GetChannelConfigsReq) (
+	*explorer.GetChannelConfigsRes, error) {
+
+	q := e.db.From(goqu.I(channelConfig).As("cc")).
+		Select("cc.id", "cc.channel_id", "cc.raw", "cc.parsed",
+			"cc.created_at", "cc.sequence", "cc.consensus_type",
+			"cc.batch_max_message_count", "cc.batch_absolute_max_bytes",
+			"cc.batch_preferred_max_bytes", "cc.batch_timeout",
+			"cc.orderer_addresses")
+
+	if req.ChannelId != 0 {
+		q = q.Where(goqu.Ex{"channel_id": req.ChannelId})
+	}
+
+	q = q.OrderAppend(goqu.I("id").Desc())
+
+	var ccs []*explorer.ChannelConfig
+
+	rows, err := q.Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		cc := &explorer.ChannelConfig{}
+		var createdAt time.Time
+		err = rows.Scan(&cc.Id, &cc.ChannelId, &cc.Raw, &cc.Parsed, &createdAt,
+			&cc.Sequence, &cc.ConsensusType, &cc.BatchMaxMessageCount,
+			&cc.BatchAbsoluteMaxBytes, &cc.BatchPreferredMaxBytes,
+			&cc.BatchTimeout, e.backend.Array(&cc.OrdererAddresses))
+		if err != nil {
+			return nil, err
+		}
+		cc.CreatedAt = timestamppb.New(createdAt)
+		ccs = append(ccs, cc)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetChannelConfigsRes{
+		ChannelConfigs: ccs,
+	}, nil
+}
+
+// channelConfigID returns given channel config ID or ID of the latest config
+// of given channel.
+func (e *Explorer) channelConfigID(ctx context.Context, channelID,
+	channelConfigID int64) (int64, error) {
+
+	if channelConfigID != 0 {
+		return channelConfigID, nil
+	}
+
+	if channelID == 0 {
+		return 0, status.Error(codes.InvalidArgument,
+			"channel_id or channel_config_id required")
+	}
+
+	var id int64
+
+	found, err := e.db.From(channelConfig).
+		Select(goqu.MAX("id")).
+		Where(goqu.Ex{"channel_id": channelID}).
+		ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, err
+	}
+	if !found || id == 0 {
+		return 0, status.Error(codes.NotFound, "channel config not found")
+	}
+
+	return id, nil
+}
+
+func (e *Explorer) GetChannelOrganizations(ctx context.Context,
+	req *explorer.GetChannelOrganizationsReq) (
+	*explorer.GetChannelOrganizationsRes, error) {
+
+	ccID, err := e.channelConfigID(ctx, req.ChannelId, req.ChannelConfigId)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs, err := e.getChannelOrganizations(ctx, ccID, req.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetChannelOrganizationsRes{
+		Organizations: orgs,
+	}, nil
+}
+
+func (e *Explorer) getChannelOrganizations(ctx context.Context,
+	channelConfigID int64, orgType string) (
+	[]*explorer.ChannelOrganization, error) {
+
+	where := goqu.Ex{"channel_config_id": channelConfigID}
+
+	if orgType != "" {
+		where["type"] = orgType
+	}
+
+	rows, err := e.db.From(channelOrganization).
+		Select("id", "channel_config_id", "type", "name", "msp_id",
+			"root_certs", "intermediate_certs", "tls_root_certs",
+			"anchor_peers", "orderer_endpoints").
+		Where(where).
+		OrderAppend(goqu.I("type").Asc(), goqu.I("name").Asc()).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var orgs []*explorer.ChannelOrganization
+
+	for rows.Next() {
+		o := &explorer.ChannelOrganization{}
+		err = rows.Scan(&o.Id, &o.ChannelConfigId, &o.Type, &o.Name,
+			&o.MspId, e.backend.Array(&o.RootCerts),
+			e.backend.Array(&o.IntermediateCerts),
+			e.backend.Array(&o.TlsRootCerts), e.backend.Array(&o.AnchorPeers),
+			e.backend.Array(&o.OrdererEndpoints))
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+func (e *Explorer) GetChannelPolicies(ctx context.Context,
+	req *explorer.GetChannelPoliciesReq) (
+	*explorer.GetChannelPoliciesRes, error) {
+
+	ccID, err := e.channelConfigID(ctx, req.ChannelId, req.ChannelConfigId)
+	if err != nil {
+		return nil, err
+	}
+
+	cps, err := e.getChannelPolicies(ctx, ccID, req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetChannelPoliciesRes{
+		Policies: cps,
+	}, nil
+}
+
+func (e *Explorer) getChannelPolicies(ctx context.Context,
+	channelConfigID int64, path string) ([]*explorer.ChannelPolicy, error) {
+
+	where := goqu.Ex{"channel_config_id": channelConfigID}
+
+	if path != "" {
+		where["path"] = path
+	}
+
+	var cps []*explorer.ChannelPolicy
+
+	err := e.db.From(channelPolicy).
+		Select("id", "channel_config_id", "path", "name", "type", "rule",
+			"mod_policy").
+		Where(where).
+		OrderAppend(goqu.I("path").Asc(), goqu.I("name").Asc()).
+		Executor().ScanStructsContext(ctx, &cps)
+	if err != nil {
+		return nil, err
+	}
+
+	return cps, nil
+}
+
+func (e *Explorer) GetChaincodes(ctx context.Context,
+	req *explorer.GetChaincodesReq) (
+	*explorer.GetChaincodesRes, error) {
+
+	q := e.db.From(goqu.I(chaincode).As("c")).
+		Select(goqu.I("c.id").As("id"),
+			goqu.I("c.name").As("name"),
+			goqu.I("c.version").As("version"))
+
+	where := goqu.Ex{}
+
+	var ccJoined bool
+
+	if req.PeerId != 0 {
+		q = q.Join(goqu.I(channelChaincode).As("cc"),
+			goqu.On(goqu.Ex{"c.id": goqu.I("cc.chaincode_id")}))
+		ccJoined = true
+		q = q.Join(goqu.I(peerChannel).As("pc"),
+			goqu.On(goqu.Ex{"cc.channel_id": goqu.I("pc.channel_id")}))
+		where["pc.peer_id"] = req.PeerId
+	}
+
+	if req.ChannelId != 0 {
+		if !ccJoined {
+			q = q.Join(goqu.I(channelChaincode).As("cc"),
+				goqu.On(goqu.Ex{"c.id": goqu.I("cc.chaincode_id")}))
+			ccJoined = true
+		}
+		where["cc.channel_id"] = req.ChannelId
+	}
+
+	q = q.Where(where).OrderAppend(goqu.I("id").Asc())
+
+	var cs []*explorer.Chaincode
+
+	err := q.Executor().ScanStructsContext(ctx, &cs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetChaincodesRes{
+		Chaincodes: cs,
+	}, nil
+}
+
+func (e *Explorer) GetChaincodeDefinitions(ctx context.Context,
+	req *explorer.GetChaincodeDefinitionsReq) (
+	*explorer.GetChaincodeDefinitionsRes, error) {
+
+	where := goqu.Ex{}
+
+	if req.ChannelId != 0 {
+		where["channel_id"] = req.ChannelId
+	}
+
+	if req.Name != "" {
+		where["name"] = req.Name
+	}
+
+	if req.Pending {
+		where["transaction_id"] = nil
+	}
+
+	// Definitions approved with parameters other than committed ones are
+	// not shown, since their approvals are not counted.
+	committed := e.db.From(goqu.T(chaincodeDefinition).As("c")).
+		Select(goqu.L("1")).
+		Where(goqu.Ex{
+			"c.channel_id":     goqu.I(chaincodeDefinition + ".channel_id"),
+			"c.name":           goqu.I(chaincodeDefinition + ".name"),
+			"c.sequence":       goqu.I(chaincodeDefinition + ".sequence"),
+			"c.transaction_id": goqu.Op{"isNot": nil},
+		})
+
+	rows, err := e.db.From(chaincodeDefinition).
+		Select("id", "channel_id", "name", "sequence", "version",
+			"endorsement_plugin", "validation_plugin", "endorsement_policy",
+			"collections", "init_required",
+			goqu.COALESCE(goqu.I("transaction_id"), "").As("transaction_id"),
+			"committed_at", "parameters_hash").
+		Where(where, goqu.Or(
+			goqu.C("transaction_id").IsNotNull(),
+			goqu.L("not exists ?", committed),
+		)).
+		OrderAppend(goqu.I("channel_id").Asc(), goqu.I("name").Asc(),
+			goqu.I("sequence").Desc(), goqu.I("id").Asc()).
+		Limit(defaultLimit).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var (
+		cds    []*explorer.ChaincodeDefinition
+		cdIDs  []int64
+		cdByID = map[int64]*explorer.ChaincodeDefinition{}
+	)
+
+	for rows.Next() {
+		cd := &explorer.ChaincodeDefinition{}
+		var committedAt sql.NullTime
+		err = rows.Scan(&cd.Id, &cd.ChannelId, &cd.Name, &cd.Sequence,
+			&cd.Version, &cd.EndorsementPlugin, &cd.ValidationPlugin,
+			&cd.EndorsementPolicy, &cd.Collections, &cd.InitRequired,
+			&cd.TransactionId, &committedAt, &cd.ParametersHash)
+		if err != nil {
+			return nil, err
+		}
+		if committedAt.Valid {
+			cd.CommittedAt = timestamppb.New(committedAt.Time)
+		}
+		cds = append(cds, cd)
+		cdIDs = append(cdIDs, cd.Id)
+		cdByID[cd.Id] = cd
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(cdIDs) == 0 {
+		return &explorer.GetChaincodeDefinitionsRes{}, nil
+	}
+
+	rows, err = e.db.From(chaincodeApproval).
+		Select("id", "chaincode_definition_id", "transaction_id", "msp_id",
+			"created_at").
+		Where(goqu.Ex{"chaincode_definition_id": cdIDs}).
+		OrderAppend(goqu.I("id").Asc()).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		ca := &explorer.ChaincodeApproval{}
+		var createdAt time.Time
+		err = rows.Scan(&ca.Id, &ca.ChaincodeDefinitionId, &ca.TransactionId,
+			&ca.MspId, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		ca.CreatedAt = timestamppb.New(createdAt)
+		cd := cdByID[ca.ChaincodeDefinitionId]
+		cd.Approvals = append(cd.Approvals, ca)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetChaincodeDefinitionsRes{
+		Definitions: cds,
+	}, nil
+}
+
+type blockLoader struct {
+	BlockID int64 `json:"block_id"`
+}
+
+func (e *Explorer) GetBlocks(ctx context.Context, req *explorer.GetBlocksReq) (
+	*explorer.GetBlocksRes, error) {
+
+	q := e.db.From(goqu.I(block).As("b")).
+		Select(goqu.I("b").All())
+
+	where := goqu.Ex{}
+
+	if req.ChannelId != 0 {
+		where["b.channel_id"] = req.ChannelId
+	}
+
+	if req.FromId != 0 {
+		where["b.id"] = goqu.Op{"lt": req.FromId}
+	}
+
+	q = q.Where(where).
+		OrderAppend(goqu.I("id").Desc()).
+		Limit(defaultLimit)
+
+	var bs []*explorer.Block
+
+	err := q.Executor().ScanStructsContext(ctx, &bs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetBlocksRes{
+		Blocks: bs,
+	}, nil
+
+}
+
+func (e *Explorer) GetBlock(ctx context.Context, req *explorer.GetBlockReq) (
+	*explorer.GetBlockRes, error) {
+
+	b := &explorer.Block{}
+
+	found, err := e.db.From(block).
+		Where(goqu.Ex{"id": req.Id}).
+		ScanStructContext(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, status.Errorf(codes.NotFound,
+			"block `%d` not found", req.Id)
+	}
+
+	signers, err := e.getIdentities(ctx, goqu.Ex{
+		"id": e.db.From(blockSignature).
+			Select("identity_id").
+			Where(goqu.Ex{"block_id": req.Id}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetBlockRes{
+		Block:   b,
+		Signers: signers,
+	}, nil
+}
+
+func (e *Explorer) transactionColumns() []interface{} {
+	return []interface{}{
+		goqu.I("transaction.id"),
+		goqu.I("transaction.channel_id"),
+		goqu.I("transaction.block_id"),
+		goqu.I("transaction.number"),
+		goqu.I("transaction.created_at"),
+		goqu.I("transaction.validation_code"),
+		e.backend.ArrayOf(`select distinct e.msp_id as value from endorsement e
+			where e.block_id = "transaction".block_id
+			and e.transaction_number = "transaction".number order by value`).
+			As("endorsing_orgs"),
+		goqu.COALESCE(goqu.I("transaction.creator_id"), 0).As("creator_id"),
+		goqu.I("transaction.function"),
+		goqu.I("transaction.type"),
+	}
+}
+
+func (e *Explorer) scanTransaction(rows *sql.Rows) (
+	*explorer.Transaction, error) {
+
+	t := &explorer.Transaction{}
+	var createdAt time.Time
+	err := rows.Scan(&t.Id, &t.ChannelId, &t.BlockId, &t.Number, &createdAt,
+		&t.ValidationCode, e.backend.Array(&t.EndorsingOrgs), &t.CreatorId,
+		&t.Function, &t.Type)
+	if err != nil {
+		return nil, err
+	}
+	t.CreatedAt = timestamppb.New(createdAt)
+	return t, nil
+}
+
+func (e *Explorer) GetTransactions(ctx context.Context,
+	req *explorer.GetTransactionsReq) (
+	*explorer.GetTransactionsRes, error) {
+
+	q := e.db.From(transaction).
+		Select(e.transactionColumns()...)
+
+	where := goqu.Ex{}
+
+	if req.ChannelId != 0 {
+		where["channel_id"] = req.ChannelId
+	}
+
+	if req.BlockId != 0 {
+		where["block_id"] = req.BlockId
+	}
+
+	if req.FromCreatedAt != nil {
+		op := "lte"
+		if req.LoadMore {
+			op = "lt"
+		}
+		where["created_at"] = goqu.Op{op: req.FromCreatedAt.AsTime()}
+	}
+
+	if req.CreatorId != 0 {
+		where["creator_id"] = req.CreatorId
+	}
+
+	if req.Function != "" {
+		where["function"] = req.Function
+	}
+
+	if req.Type != "" {
+		where["type"] = req.Type
+	}
+
+	switch req.Validity {
+	case explorer.TransactionValidity_TRANSACTION_VALIDITY_VALID:
+		where["validation_code"] = validTransaction
+	case explorer.TransactionValidity_TRANSACTION_VALIDITY_INVALID:
+		where["validation_code"] = goqu.Op{"neq": validTransaction}
+	}
+
+	q = q.Where(where).
+		OrderAppend(goqu.I("created_at").Desc()).
+		Limit(defaultLimit)
+
+	rows, err := q.Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ts []*explorer.Transaction
+
+	for rows.Next() {
+		t, err := e.scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetTransactionsRes{
+		Transactions: ts,
+	}, nil
+}
+
+func (e *Explorer) GetTransaction(ctx context.Context,
+	req *explorer.GetTransactionReq) (
+	*explorer.GetTransactionRes, error) {
+
+	where := goqu.Ex{"id": req.Id}
+
+	if req.BlockId != 0 {
+		where["block_id"] = req.BlockId
+		where["number"] = req.Number
+	}
+
+	rows, err := e.db.From(transaction).
+		Select(e.transactionColumns()...).
+		Where(where).
+		Order(goqu.I("block_id").Asc(), goqu.I("number").Asc()).
+		Limit(1).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.NotFound,
+			"transaction `%s` not found", req.Id)
+	}
+
+	t, err := e.scanTransaction(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byTransaction := goqu.Ex{
+		"block_id":           t.BlockId,
+		"transaction_number": t.Number,
+	}
+
+	_, err = e.db.From(transaction).
+		Select("raw_payload").
+		Where(goqu.Ex{"block_id": t.BlockId, "number": t.Number}).
+		ScanValContext(ctx, &t.RawPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*explorer.Read
+
+	err = e.db.From(read).
+		Select("id", "transaction_id",
+			goqu.COALESCE(goqu.I("range_query_id"), 0).As("range_query_id"),
+			"chaincode", "key", "has_version", "version_block_number",
+			"version_tx_number").
+		Where(byTransaction).
+		OrderAppend(goqu.I("id").Asc()).
+		Executor().ScanStructsContext(ctx, &rs)
+	if err != nil {
+		return nil, err
+	}
+
+	var rqs []*explorer.RangeQuery
+
+	err = e.db.From(rangeQuery).
+		Select("id", "transaction_id", "chaincode", "start_key", "end_key",
+			"itr_exhausted").
+		Where(byTransaction).
+		OrderAppend(goqu.I("id").Asc()).
+		Executor().ScanStructsContext(ctx, &rqs)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		pwhs []*explorer.PrivateWriteHash
+		ces  []*explorer.ChaincodeEvent
+	)
+
+	// Writes and events are stored for valid transactions only, and valid
+	// transaction is the only one with its ID.
+	if t.ValidationCode == validTransaction {
+		pwhs, err = e.getPrivateWriteHashes(ctx,
+			goqu.Ex{"transaction_id": t.Id}, false)
+		if err != nil {
+			return nil, err
+		}
+
+		ces, err = e.getChaincodeEvents(ctx,
+			goqu.Ex{"transaction_id": t.Id}, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var ens []*explorer.Endorsement
+
+	err = e.db.From(endorsement).
+		Select("id", "transaction_id", "msp_id", "subject", "fingerprint").
+		Where(byTransaction).
+		OrderAppend(goqu.I("id").Asc()).
+		Executor().ScanStructsContext(ctx, &ens)
+	if err != nil {
+		return nil, err
+	}
+
+	var as []*explorer.Argument
+
+	err = e.db.From(argument).
+		Select("id", "transaction_id", "index", "type", "raw_value",
+			"value").
+		Where(byTransaction).
+		OrderAppend(goqu.I("index").Asc()).
+		Executor().ScanStructsContext(ctx, &as)
+	if err != nil {
+		return nil, err
+	}
+
+	var creator *explorer.Identity
+
+	if t.CreatorId != 0 {
+		is, err := e.getIdentities(ctx, goqu.Ex{"id": t.CreatorId})
+		if err != nil {
+			return nil, err
+		}
+		if len(is) > 0 {
+			creator = is[0]
+		}
+	}
+
+	return &explorer.GetTransactionRes{
+		Transaction:        t,
+		Creator:            creator,
+		Arguments:          as,
+		Endorsements:       ens,
+		Reads:              rs,
+		RangeQueries:       rqs,
+		PrivateWriteHashes: pwhs,
+		ChaincodeEvents:    ces,
+	}, nil
+}
+
+func (e *Explorer) GetStates(ctx context.Context, req *explorer.GetStatesReq) (
+	*explorer.GetStatesRes, error) {
+
+	q := e.db.From(goqu.I(state).As("s")).
+		Select("s.key", "s.channel_id", "s.transaction_id", "s.type",
+			"s.raw_value", "s.value", "s.created_at", "s.chaincode",
+			"s.version_block_number", "s.version_tx_number")
+
+	where := goqu.Ex{}
+
+	if req.ChannelId != 0 {
+		where["s.channel_id"] = req.ChannelId
+	}
+
+	if req.TransactionId != "" {
+		where["s.transaction_id"] = req.TransactionId
+	}
+
+	if req.Chaincode != "" {
+		where["s.chaincode"] = req.Chaincode
+	}
+
+	if req.FromCreatedAt != nil {
+		op := "lte"
+		if req.LoadMore {
+			op = "lt"
+		}
+		where["s.created_at"] = goqu.Op{op: req.FromCreatedAt.AsTime()}
+	}
+
+	q = q.Where(where).
+		OrderAppend(goqu.I("key").Asc()).
+		Limit(defaultLimit)
+
+	rows, err := q.Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ss []*explorer.State
+
+	for rows.Next() {
+		s := &explorer.State{}
+		var createdAt time.Time
+		err = rows.Scan(&s.Key, &s.ChannelId, &s.TransactionId, &s.Type,
+			&s.RawValue, &s.Value, &createdAt, &s.Chaincode,
+			&s.VersionBlockNumber, &s.VersionTxNumber)
+		if err != nil {
+			return nil, err
+		}
+		s.CreatedAt = timestamppb.New(createdAt)
+		ss = append(ss, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetStatesRes{
+		States: ss,
+	}, nil
+
+}
+
+func (e *Explorer) GetOldStates(ctx context.Context,
+	req *explorer.GetOldStatesReq) (
+	*explorer.GetOldStatesRes, error) {
+
+	q := e.db.From(oldState).Select("id", "channel_id",
+		"transaction_id", "key", "type", "raw_value", "value", "created_at",
+		"deleted", "chaincode", "version_block_number", "version_tx_number")
+
+	where := goqu.Ex{}
+
+	if req.Key != "" {
+		where["key"] = req.Key
+	}
+
+	if req.ChannelId != 0 {
+		where["channel_id"] = req.ChannelId
+	}
+
+	if req.Chaincode != "" {
+		where["chaincode"] = req.Chaincode
+	}
+
+	if req.FromId != 0 {
+		where["id"] = goqu.Op{"lt": req.FromId}
+	}
+
+	q = q.Where(where).
+		OrderAppend(goqu.I("id").Desc()).
+		Limit(defaultLimit)
+
+	rows, err := q.Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ss []*explorer.OldState
+
+	for rows.Next() {
+		s := &explorer.OldState{}
+		var createdAt time.Time
+		err = rows.Scan(&s.Id, &s.ChannelId, &s.TransactionId, &s.Key, &s.Type,
+			&s.RawValue, &s.Value, &createdAt, &s.Deleted, &s.Chaincode,
+			&s.VersionBlockNumber, &s.VersionTxNumber)
+		if err != nil {
+			return nil, err
+		}
+		s.CreatedAt = timestamppb.New(createdAt)
+		ss = append(ss, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetOldStatesRes{
+		OldStates: ss,
+	}, nil
+
+}
+
+func (e *Explorer) GetPrivateWriteHashes(ctx context.Context,
+	req *explorer.GetPrivateWriteHashesReq) (
+	*explorer.GetPrivateWriteHashesRes, error) {
+
+	where := goqu.Ex{}
+
+	if req.ChannelId != 0 {
+		where["channel_id"] = req.ChannelId
+	}
+
+	if req.Chaincode != "" {
+		where["chaincode"] = req.Chaincode
+	}
+
+	if req.Collection != "" {
+		where["collection"] = req.Collection
+	}
+
+	if req.TransactionId != "" {
+		where["transaction_id"] = req.TransactionId
+	}
+
+	if req.FromId != 0 {
+		where["id"] = goqu.Op{"lt": req.FromId}
+	}
+
+	pwhs, err := e.getPrivateWriteHashes(ctx, where, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetPrivateWriteHashesRes{
+		PrivateWriteHashes: pwhs,
+	}, nil
+}
+
+func (e *Explorer) getPrivateWriteHashes(ctx context.Context, where goqu.Ex,
+	limit bool) ([]*explorer.PrivateWriteHash, error) {
+
+	q := e.db.From(privateWriteHash).
+		Select("id", "channel_id", "transaction_id", "chaincode",
+			"collection", "key_hash", "value_hash", "is_delete", "created_at").
+		Where(where).
+		OrderAppend(goqu.I("id").Desc())
+
+	if limit {
+		q = q.Limit(defaultLimit)
+	}
+
+	rows, err := q.Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var pwhs []*explorer.PrivateWriteHash
+
+	for rows.Next() {
+		pwh := &explorer.PrivateWriteHash{}
+		var createdAt time.Time
+		err = rows.Scan(&pwh.Id, &pwh.ChannelId, &pwh.TransactionId,
+			&pwh.Chaincode, &pwh.Collection, &pwh.KeyHash, &pwh.ValueHash,
+			&pwh.IsDelete, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		pwh.CreatedAt = timestamppb.New(createdAt)
+		pwhs = append(pwhs, pwh)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pwhs, nil
+}
+
+func (e *Explorer) GetPrivateWrites(ctx context.Context,
+	req *explorer.GetPrivateWritesReq) (
+	*explorer.GetPrivateWritesRes, error) {
+
+	q := e.db.From(privateWrite).
+		Select("id", "channel_id", "transaction_id", "chaincode",
+			"collection", "key", "type", "raw_value", "value", "is_delete",
+			"created_at")
+
+	where := goqu.Ex{}
+
+	if req.ChannelId != 0 {
+		where["channel_id"] = req.ChannelId
+	}
+
+	if req.Chaincode != "" {
+		where["chaincode"] = req.Chaincode
+	}
+
+	if req.Collection != "" {
+		where["collection"] = req.Collection
+	}
+
+	if req.TransactionId != "" {
+		where["transaction_id"] = req.TransactionId
+	}
+
+	if req.Key != "" {
+		where["key"] = req.Key
+	}
+
+	if req.FromId != 0 {
+		where["id"] = goqu.Op{"lt": req.FromId}
+	}
+
+	q = q.Where(where).
+		OrderAppend(goqu.I("id").Desc()).
+		Limit(defaultLimit)
+
+	rows, err := q.Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var pws []*explorer.PrivateWrite
+
+	for rows.Next() {
+		pw := &explorer.PrivateWrite{}
+		var createdAt time.Time
+		err = rows.Scan(&pw.Id, &pw.ChannelId, &pw.TransactionId,
+			&pw.Chaincode, &pw.Collection, &pw.Key, &pw.Type, &pw.RawValue,
+			&pw.Value, &pw.IsDelete, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		pw.CreatedAt = timestamppb.New(createdAt)
+		pws = append(pws, pw)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetPrivateWritesRes{
+		PrivateWrites: pws,
+	}, nil
+}
+
+func (e *Explorer) GetChaincodeEvents(ctx context.Context,
+	req *explorer.GetChaincodeEventsReq) (
+	*explorer.GetChaincodeEventsRes, error) {
+
+	where := goqu.Ex{}
+
+	if req.ChannelId != 0 {
+		where["channel_id"] = req.ChannelId
+	}
+
+	if req.Chaincode != "" {
+		where["chaincode"] = req.Chaincode
+	}
+
+	if req.Name != "" {
+		where["name"] = req.Name
+	}
+
+	switch {
+	case req.FromCreatedAt != nil && req.ToCreatedAt != nil:
+		where["created_at"] = goqu.Op{"between": goqu.Range(
+			req.FromCreatedAt.AsTime(), req.ToCreatedAt.AsTime())}
+	case req.FromCreatedAt != nil:
+		where["created_at"] = goqu.Op{"gte": req.FromCreatedAt.AsTime()}
+	case req.ToCreatedAt != nil:
+		where["created_at"] = goqu.Op{"lte": req.ToCreatedAt.AsTime()}
+	}
+
+	if req.FromId != 0 {
+		where["id"] = goqu.Op{"lt": req.FromId}
+	}
+
+	ces, err := e.getChaincodeEvents(ctx, where, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetChaincodeEventsRes{
+		ChaincodeEvents: ces,
+	}, nil
+}
+
+func (e *Explorer) getChaincodeEvents(ctx context.Context, where goqu.Ex,
+	limit bool) ([]*explorer.ChaincodeEvent, error) {
+
+	q := e.db.From(chaincodeEvent).
+		Select("id", "channel_id", "transaction_id", "chaincode", "name",
+			"type", "raw_payload", "payload", "created_at").
+		Where(where).
+		OrderAppend(goqu.I("id").Desc())
+
+	if limit {
+		q = q.Limit(defaultLimit)
+	}
+
+	rows, err := q.Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ces []*explorer.ChaincodeEvent
+
+	for rows.Next() {
+		ce := &explorer.ChaincodeEvent{}
+		var createdAt time.Time
+		err = rows.Scan(&ce.Id, &ce.ChannelId, &ce.TransactionId,
+			&ce.Chaincode, &ce.Name, &ce.Type, &ce.RawPayload, &ce.Payload,
+			&createdAt)
+		if err != nil {
+			return nil, err
+		}
+		ce.CreatedAt = timestamppb.New(createdAt)
+		ces = append(ces, ce)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ces, nil
+}
+
+func (e *Explorer) GetIdentities(ctx context.Context,
+	req *explorer.GetIdentitiesReq) (
+	*explorer.GetIdentitiesRes, error) {
+
+	where := goqu.Ex{}
+
+	if req.MspId != "" {
+		where["msp_id"] = req.MspId
+	}
+
+	if req.FromId != 0 {
+		where["id"] = goqu.Op{"lt": req.FromId}
+	}
+
+	is, err := e.getIdentities(ctx, where)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explorer.GetIdentitiesRes{
+		Identities: is,
+	}, nil
+}
+
+func (e *Explorer) getIdentities(ctx context.Context, where goqu.Ex) (
+	[]*explorer.Identity, error) {
+
+	rows, err := e.db.From(identity).
+		Select("id", "msp_id", "fingerprint", "subject", "issuer",
+			"serial_number", "not_after").
+		Where(where).
+		OrderAppend(goqu.I("id").Desc()).
+		Limit(defaultLimit).
+		Executor().QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var is []*explorer.Identity
+
+	for rows.Next() {
+		i := &explorer.Identity{}
+		var notAfter sql.NullTime
+		err = rows.Scan(&i.Id, &i.MspId, &i.Fingerprint, &i.Subject,
+			&i.Issuer, &i.SerialNumber, &notAfter)
+		if err != nil {
+			return nil, err
+		}
+		if notAfter.Valid {
+			i.NotAfter = timestamppb.New(notAfter.Time)
+		}
+		is = append(is, i)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return is, nil
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"explorer"
+	"explorer/hf"
+)
+
+const verifyBatchSize = 1000
+
+func (e *Explorer) VerifyBlocks(ctx context.Context,
+	req *explorer.VerifyBlocksReq) (*explorer.VerifyBlocksRes, error) {
+
+	if req.ChannelId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "channel_id required")
+	}
+
+	return e.verifyBlocks(ctx, req.ChannelId)
+}
+
+// verifyBlocks walks channel blocks in number order and checks that stored
+// header hash of every block matches its header fields and previous hash of
+// every block matches header hash of the prior block. Walk stops at the
+// first broken link.
+func (e *Explorer) verifyBlocks(ctx context.Context, channelID int64) (
+	*explorer.VerifyBlocksRes, error) {
+
+	res := &explorer.VerifyBlocksRes{}
+
+	var (
+		prev     *explorer.Block
+		fromNext int64
+	)
+
+	for {
+		var bs []*explorer.Block
+
+		err := e.db.From(block).
+			Select("id", "channel_id", "number", "data_hash",
+				"previous_hash", "hash", "transaction_count", "size").
+			Where(goqu.Ex{
+				"channel_id": channelID,
+				"number":     goqu.Op{"gte": fromNext},
+			}).
+			OrderAppend(goqu.I("number").Asc()).
+			Limit(verifyBatchSize).
+			Executor().ScanStructsContext(ctx, &bs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range bs {
+			brokenLink, err := verifyBlock(prev, b)
+			if err != nil {
+				return nil, err
+			}
+			if brokenLink != nil {
+				res.BrokenLink = brokenLink
+				return res, nil
+			}
+			res.VerifiedBlocks++
+			prev = b
+		}
+
+		if len(bs) < verifyBatchSize {
+			break
+		}
+
+		fromNext = prev.Number + 1
+	}
+
+	res.Valid = true
+
+	return res, nil
+}
+
+func verifyBlock(prev, b *explorer.Block) (*explorer.BrokenBlockLink, error) {
+
+	brokenLink := &explorer.BrokenBlockLink{
+		BlockNumber:  b.Number,
+		PreviousHash: b.PreviousHash,
+	}
+
+	if b.Hash == "" {
+		brokenLink.Reason = "block hash not stored"
+		return brokenLink, nil
+	}
+
+	previousHash, err := hex.DecodeString(b.PreviousHash)
+	if err != nil {
+		return nil, fmt.Errorf("decode previous hash of block %d: %w",
+			b.Number, err)
+	}
+
+	dataHash, err := hex.DecodeString(b.DataHash)
+	if err != nil {
+		return nil, fmt.Errorf("decode data hash of block %d: %w",
+			b.Number, err)
+	}
+
+	hash := hex.EncodeToString(hf.BlockHeaderHash(uint64(b.Number),
+		previousHash, dataHash))
+	if hash != b.Hash {
+		brokenLink.Reason = "block hash does not match block header"
+		return brokenLink, nil
+	}
+
+	if prev == nil {
+		if b.Number != 0 {
+			brokenLink.Reason = fmt.Sprintf("blocks before %d not found",
+				b.Number)
+			return brokenLink, nil
+		}
+		return nil, nil
+	}
+
+	brokenLink.ExpectedPreviousHash = prev.Hash
+
+	if b.Number != prev.Number+1 {
+		brokenLink.Reason = fmt.Sprintf("block %d not found", prev.Number+1)
+		return brokenLink, nil
+	}
+
+	if b.PreviousHash != prev.Hash {
+		brokenLink.Reason = "previous hash does not match prior block hash"
+		return brokenLink, nil
+	}
+
+	return nil, nil
+}
+
+// Verify verifies hash chain of blocks of all channels and returns error if
+// any channel has a broken link. Database is migrated first, like on Run.
+func (e *Explorer) Verify() error {
+
+	err := e.Migrate()
+	if err != nil {
+		return fmt.Errorf("migrate DB: %w", err)
+	}
+
+	sqlDB, err := sql.Open(e.backend.Driver(), e.config.DSN)
+	if err != nil {
+		return fmt.Errorf("open DB: %w", err)
+	}
+
+	defer func() {
+		err := sqlDB.Close()
+		if err != nil {
+			e.log.WithError(err).Error("failed to close DB")
+		}
+	}()
+
+	e.db = goqu.New(e.backend.Dialect(), sqlDB)
+
+	ctx := context.Background()
+
+	var cs []*explorer.Channel
+
+	err = e.db.From(channel).
+		Select("id", "name").
+		OrderAppend(goqu.I("id").Asc()).
+		Executor().ScanStructsContext(ctx, &cs)
+	if err != nil {
+		return fmt.Errorf("get channels from DB: %w", err)
+	}
+
+	var broken bool
+
+	for _, c := range cs {
+		log := e.log.WithField("channel_name", c.Name)
+
+		res, err := e.verifyBlocks(ctx, c.Id)
+		if err != nil {
+			return fmt.Errorf("verify blocks of channel `%s`: %w",
+				c.Name, err)
+		}
+
+		if res.Valid {
+			log.WithField("verified_blocks", res.VerifiedBlocks).
+				Info("blocks hash chain is valid")
+			continue
+		}
+
+		broken = true
+
+		log.WithFields(logrus.Fields{
+			"verified_blocks":        res.VerifiedBlocks,
+			"block_number":           res.BrokenLink.BlockNumber,
+			"previous_hash":          res.BrokenLink.PreviousHash,
+			"expected_previous_hash": res.BrokenLink.ExpectedPreviousHash,
+			"reason":                 res.BrokenLink.Reason,
+		}).Error("blocks hash chain is broken")
+	}
+
+	if broken {
+		return fmt.Errorf("broken blocks hash chain found")
+	}
+
+	return nil
+}
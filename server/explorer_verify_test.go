@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"explorer"
+	"explorer/hf"
+)
+
+// testChainBlock returns block with the given previous hash and header hash
+// computed from its header.
+func testChainBlock(number int64, previousHash string) *explorer.Block {
+	ph, _ := hex.DecodeString(previousHash)
+	dataHash := []byte{byte(number), 0xda, 0x7a}
+
+	return &explorer.Block{
+		Number:       number,
+		PreviousHash: previousHash,
+		DataHash:     hex.EncodeToString(dataHash),
+		Hash: hex.EncodeToString(hf.BlockHeaderHash(uint64(number), ph,
+			dataHash)),
+	}
+}
+
+func TestVerifyBlock(t *testing.T) {
+	var (
+		b0 = testChainBlock(0, "")
+		b1 = testChainBlock(1, b0.Hash)
+		b2 = testChainBlock(2, b1.Hash)
+	)
+
+	tamperedB1 := testChainBlock(1, b0.Hash)
+	tamperedB1.DataHash = "00"
+
+	forkedB1 := testChainBlock(1, hex.EncodeToString([]byte("fork")))
+
+	notHashedB1 := testChainBlock(1, b0.Hash)
+	notHashedB1.Hash = ""
+
+	malformedB1 := testChainBlock(1, b0.Hash)
+	malformedB1.PreviousHash = "not hex"
+
+	for _, c := range []struct {
+		name        string
+		prev, block *explorer.Block
+		reason      string
+		expected    string
+		wantErr     bool
+	}{{
+		name:  "first block",
+		block: b0,
+	}, {
+		name:  "valid link",
+		prev:  b0,
+		block: b1,
+	}, {
+		name:   "blocks before first block not found",
+		block:  b1,
+		reason: "blocks before 1 not found",
+	}, {
+		name:     "gap",
+		prev:     b0,
+		block:    b2,
+		reason:   "block 1 not found",
+		expected: b0.Hash,
+	}, {
+		name:     "hash mismatch",
+		prev:     b0,
+		block:    tamperedB1,
+		reason:   "block hash does not match block header",
+		expected: "",
+	}, {
+		name:     "previous hash mismatch",
+		prev:     b0,
+		block:    forkedB1,
+		reason:   "previous hash does not match prior block hash",
+		expected: b0.Hash,
+	}, {
+		name:   "hash not stored",
+		prev:   b0,
+		block:  notHashedB1,
+		reason: "block hash not stored",
+	}, {
+		name:    "malformed previous hash",
+		prev:    b0,
+		block:   malformedB1,
+		wantErr: true,
+	}} {
+		link, err := verifyBlock(c.prev, c.block)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: got error %v, want error %t", c.name, err,
+				c.wantErr)
+			continue
+		}
+
+		if c.reason == "" {
+			if link != nil {
+				t.Errorf("%s: got broken link %v, want none", c.name, link)
+			}
+			continue
+		}
+
+		if link == nil {
+			t.Errorf("%s: got no broken link, want `%s`", c.name, c.reason)
+			continue
+		}
+
+		if link.Reason != c.reason || link.BlockNumber != c.block.Number ||
+			link.PreviousHash != c.block.PreviousHash ||
+			link.ExpectedPreviousHash != c.expected {
+			t.Errorf("%s: got broken link %v, want block %d reason `%s` "+
+				"expected previous hash `%s`", c.name, link, c.block.Number,
+				c.reason, c.expected)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package sqlite is a SQLite backend of explorer.
+//
+// DSN of config is a path of SQLite database file with optional go-sqlite3
+// parameters. Database is used by several connections, so WAL journal, busy
+// timeout and immediate transactions are recommended, for example
+// `explorer.db?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate`.
+// In-memory database must be shared between connections, for example
+// `file:explorer?mode=memory&cache=shared`.
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	goquSQLite3 "github.com/doug-martin/goqu/v9/dialect/sqlite3"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/golang-migrate/migrate/v4"
+	migrateSQLite3 "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+
+	"explorer/hf"
+	"explorer/server"
+	_ "explorer/sqlite/migrations"
+)
+
+const (
+	driverName    = "sqlite3"
+	sqliteDialect = "sqlite3_explorer"
+
+	// timeFormat is a fixed width UTC time format, so stored times are
+	// ordered as text.
+	timeFormat = "2006-01-02 15:04:05.000000000"
+)
+
+func init() {
+	opts := goquSQLite3.DialectOptions()
+	opts.TimeFormat = timeFormat
+	goqu.RegisterDialect(sqliteDialect, opts)
+}
+
+type (
+	ExplorerConfig = server.Config
+	Explorer       = server.Explorer
+	Query          = server.Query
+)
+
+func NewExplorer(c ExplorerConfig) (*Explorer, error) {
+	return server.NewExplorer(c, backend{})
+}
+
+func RunExplorer() {
+	server.RunExplorer(backend{})
+}
+
+func RegisterUI(fs embed.FS) {
+	server.RegisterUI(fs)
+}
+
+// RegisterQuery registers DB query, see server.RegisterQuery.
+func RegisterQuery(q Query) {
+	server.RegisterQuery(q)
+}
+
+// backend is a SQLite backend of explorer. Text arrays are stored as JSON
+// arrays.
+type backend struct{}
+
+func (backend) Name() string {
+	return "sqlite"
+}
+
+func (backend) Driver() string {
+	return driverName
+}
+
+func (backend) Dialect() string {
+	return sqliteDialect
+}
+
+func (backend) Migrate(sqlDB *sql.DB) error {
+
+	d, err := migrateSQLite3.WithInstance(sqlDB, &migrateSQLite3.Config{
+		MigrationsTable: "migration",
+	})
+	if err != nil {
+		return fmt.Errorf("create driver: %w", err)
+	}
+
+	m, err := migrate.NewWithDatabaseInstance(
+		"embed-sqlite://", driverName, d)
+	if err != nil {
+		return fmt.Errorf("create migrator: %w", err)
+	}
+
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	return nil
+}
+
+func (backend) Storage(db *goqu.Database, log *logrus.Entry) hf.Storage {
+	return &storage{db: db, log: log}
+}
+
+func (backend) Array(a *[]string) interface{} {
+	return (*textArray)(a)
+}
+
+func (backend) ArrayOf(query string) exp.LiteralExpression {
+	return goqu.L("(select json_group_array(value) from (" + query + "))")
+}
@@ -0,0 +1,753 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/sirupsen/logrus"
+
+	"explorer"
+	"explorer/hf"
+)
+
+const (
+	peer             = "peer"
+	channel          = "channel"
+	peerChannel      = "peer_channel"
+	channelConfig    = "channel_config"
+	chaincode        = "chaincode"
+	channelChaincode = "channel_chaincode"
+	block            = "block"
+	blockSignature   = "block_signature"
+	blockRaw         = "block_raw"
+	checkpoint       = "checkpoint"
+	transaction      = "transaction"
+	identity         = "identity"
+	argument         = "argument"
+	endorsement      = "endorsement"
+	rangeQuery       = "range_query"
+	read             = "read"
+	privateWriteHash = "private_write_hash"
+	privateWrite     = "private_write"
+	chaincodeEvent   = "chaincode_event"
+	state            = "state"
+	oldState         = "old_state"
+
+	chaincodeDefinition = "chaincode_definition"
+	chaincodeApproval   = "chaincode_approval"
+	channelOrganization = "channel_organization"
+	channelPolicy       = "channel_policy"
+	failedBlock         = "failed_block"
+)
+
+// storage stores data of processors in SQLite.
+type storage struct {
+	db  *goqu.Database
+	log *logrus.Entry
+}
+
+func (s *storage) LastBlockNumber(ctx context.Context, channelName string) (
+	number int64, found bool, err error) {
+
+	found, err = s.db.
+		From(goqu.I(checkpoint).As("cp")).
+		Join(goqu.I(channel).As("c"),
+			goqu.On(goqu.Ex{"cp.channel_id": goqu.I("c.id")})).
+		Select("cp.block_number").
+		Where(goqu.Ex{"c.name": channelName}).
+		ScanValContext(ctx, &number)
+	return
+}
+
+// RawBlocks returns stored raw blocks of the channel starting from the given
+// block number in number order.
+func (s *storage) RawBlocks(ctx context.Context, channelName string,
+	fromNumber int64, limit int) ([]*explorer.RawBlock, error) {
+
+	var rbs []*explorer.RawBlock
+
+	err := s.db.
+		From(goqu.I(blockRaw).As("br")).
+		Join(goqu.I(channel).As("c"),
+			goqu.On(goqu.Ex{"br.channel_id": goqu.I("c.id")})).
+		Select("br.channel_id", "br.number", "br.compression",
+			"br.source_url", "br.data").
+		Where(goqu.Ex{
+			"c.name":    channelName,
+			"br.number": goqu.Op{"gte": fromNumber},
+		}).
+		OrderAppend(goqu.I("br.number").Asc()).
+		Limit(uint(limit)).
+		ScanStructsContext(ctx, &rbs)
+	if err != nil {
+		return nil, err
+	}
+
+	return rbs, nil
+}
+
+// ResetChannel removes all indexed data of the channel except raw blocks,
+// so the channel can be reindexed from them.
+func (s *storage) ResetChannel(ctx context.Context,
+	channelName string) (err error) {
+
+	var channelID int64
+
+	found, err := s.db.From(channel).
+		Select("id").
+		Where(goqu.Ex{"name": channelName}).
+		ScanValContext(ctx, &channelID)
+	if err != nil {
+		return fmt.Errorf("get channel from DB: %w", err)
+	}
+	if !found {
+		return nil
+	}
+
+	tx, err := s.db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err2 := tx.Rollback()
+			if err2 != nil {
+				s.log.WithError(err2).
+					Error("failed to rollback transaction")
+			}
+		}
+	}()
+
+	txx := goqu.NewTx(sqliteDialect, tx)
+
+	byChannel := goqu.Ex{"channel_id": channelID}
+
+	byBlock := goqu.Ex{"block_id": txx.From(block).
+		Select("id").Where(byChannel)}
+
+	byChannelConfig := goqu.Ex{"channel_config_id": txx.From(channelConfig).
+		Select("id").Where(byChannel)}
+
+	byChaincodeDefinition := goqu.Ex{"chaincode_definition_id": txx.
+		From(chaincodeDefinition).Select("id").Where(byChannel)}
+
+	// Tables are cleared in order of references between them.
+	for _, d := range []struct {
+		table string
+		where goqu.Ex
+	}{
+		{chaincodeApproval, byChaincodeDefinition},
+		{chaincodeDefinition, byChannel},
+		{argument, byBlock},
+		{endorsement, byBlock},
+		{read, byBlock},
+		{rangeQuery, byBlock},
+		{privateWriteHash, byChannel},
+		{privateWrite, byChannel},
+		{chaincodeEvent, byChannel},
+		{state, byChannel},
+		{oldState, byChannel},
+		{transaction, byChannel},
+		{blockSignature, byBlock},
+		{block, byChannel},
+		{channelOrganization, byChannelConfig},
+		{channelPolicy, byChannelConfig},
+		{channelConfig, byChannel},
+		{checkpoint, byChannel},
+		{failedBlock, byChannel},
+	} {
+		_, err = txx.Delete(d.table).
+			Where(d.where).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("delete from %s: %w", d.table, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Begin begins database transaction as storage unit of work.
+func (s *storage) Begin(ctx context.Context) (hf.StorageTx, error) {
+	tx, err := s.db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &storageTx{
+		tx:  tx,
+		txx: goqu.NewTx(sqliteDialect, tx),
+	}, nil
+}
+
+// storageTx is a storage unit of work in database transaction. SQLite is
+// embedded, so blocks are written row by row without round trip cost.
+type storageTx struct {
+	tx  *sql.Tx
+	txx *goqu.TxDatabase
+}
+
+func (t *storageTx) WriteBlocks(ctx context.Context,
+	blocks []*hf.DecodedBlock) error {
+
+	for _, b := range blocks {
+		err := writeBlockTx(ctx, t.txx, b)
+		if err != nil {
+			return fmt.Errorf("write block %d: %w", b.Block.Number, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *storageTx) WriteFailedBlock(ctx context.Context, channelName string,
+	fb *explorer.FailedBlock, rb *explorer.RawBlock) error {
+
+	channelID, err := addTx(ctx, t.txx, channel,
+		goqu.Ex{"name": channelName}, goqu.Record{"name": channelName})
+	if err != nil {
+		return fmt.Errorf("add channel to DB: %w", err)
+	}
+
+	if rb != nil {
+		rb.ChannelId = channelID
+		_, err = t.txx.Insert(blockRaw).
+			Rows(rawBlockRecord(rb)).
+			OnConflict(goqu.DoNothing()).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("add raw block to DB: %w", err)
+		}
+	}
+
+	fb.ChannelId = channelID
+
+	_, err = t.txx.
+		Insert(failedBlock).
+		Rows(goqu.Record{
+			"channel_id":  fb.ChannelId,
+			"number":      fb.Number,
+			"compression": fb.Compression,
+			"source_url":  fb.SourceUrl,
+			"data":        blob(fb.Data),
+			"error":       fb.Error,
+			"failures":    fb.Failures,
+		}).
+		OnConflict(goqu.DoUpdate("channel_id, number", goqu.Record{
+			"compression": goqu.I("excluded.compression"),
+			"source_url":  goqu.I("excluded.source_url"),
+			"data":        goqu.I("excluded.data"),
+			"error":       goqu.I("excluded.error"),
+			"failures": goqu.L("? + ?", goqu.I(failedBlock+".failures"),
+				goqu.I("excluded.failures")),
+		})).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("add failed block to DB: %w", err)
+	}
+
+	err = setLastBlockNumberTx(ctx, t.txx, channelID, fb.Number)
+	if err != nil {
+		return fmt.Errorf("set last block number in DB: %w", err)
+	}
+
+	return nil
+}
+
+func (t *storageTx) DeleteFailedBlock(ctx context.Context, channelName string,
+	number int64) error {
+
+	_, err := t.txx.
+		Delete(failedBlock).
+		Where(goqu.Ex{
+			"channel_id": t.txx.From(channel).Select("id").
+				Where(goqu.Ex{"name": channelName}),
+			"number": number,
+		}).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("delete failed block from DB: %w", err)
+	}
+
+	return nil
+}
+
+func (t *storageTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *storageTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// addTx adds row if row with the key does not exist yet and returns ID of
+// the row.
+func addTx(ctx context.Context, txx *goqu.TxDatabase, table string,
+	key goqu.Ex, row interface{}) (id int64, err error) {
+
+	found, err := txx.From(table).
+		Select("id").
+		Where(key).
+		ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		return id, nil
+	}
+
+	return insertTx(ctx, txx, table, row)
+}
+
+// insertTx inserts row and returns its ID.
+func insertTx(ctx context.Context, txx *goqu.TxDatabase, table string,
+	row interface{}) (int64, error) {
+
+	res, err := txx.Insert(table).
+		Rows(row).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// insertAllTx inserts rows with a multi-row insert.
+func insertAllTx(ctx context.Context, txx *goqu.TxDatabase, table string,
+	rows []goqu.Record) error {
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, err := txx.Insert(table).
+		Rows(rows).
+		Executor().ExecContext(ctx)
+	return err
+}
+
+// setLastBlockNumberTx sets checkpoint of the channel. Checkpoint never moves
+// back, so retry of old failed block does not cause reprocessing of blocks
+// after it.
+func setLastBlockNumberTx(ctx context.Context, txx *goqu.TxDatabase,
+	channelID int64, number int64) error {
+
+	_, err := txx.
+		Insert(checkpoint).
+		Rows(goqu.Record{
+			"channel_id":   channelID,
+			"block_number": number,
+		}).
+		OnConflict(goqu.DoUpdate("channel_id", goqu.Record{
+			"block_number": goqu.L("MAX(?, ?)",
+				goqu.I(checkpoint+".block_number"),
+				goqu.I("excluded.block_number")),
+		})).
+		Executor().ExecContext(ctx)
+	return err
+}
+
+func writeBlockTx(ctx context.Context, txx *goqu.TxDatabase,
+	b *hf.DecodedBlock) (err error) {
+
+	peerID, err := addTx(ctx, txx, peer, goqu.Ex{"url": b.SourceURL},
+		goqu.Record{"url": b.SourceURL})
+	if err != nil {
+		return fmt.Errorf("add peer to DB: %w", err)
+	}
+
+	channelID, err := addTx(ctx, txx, channel, goqu.Ex{"name": b.ChannelName},
+		goqu.Record{"name": b.ChannelName})
+	if err != nil {
+		return fmt.Errorf("add channel to DB: %w", err)
+	}
+
+	_, err = txx.Insert(peerChannel).
+		Rows(goqu.Record{
+			"peer_id":    peerID,
+			"channel_id": channelID,
+		}).
+		OnConflict(goqu.DoNothing()).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("add peer channel to DB: %w", err)
+	}
+
+	for _, cc := range b.ChannelConfigs {
+		cc.Config.ChannelId = channelID
+		cc.Config.Id, err = insertTx(ctx, txx, channelConfig,
+			channelConfigRecord(cc.Config))
+		if err != nil {
+			return fmt.Errorf("add channel config to DB: %w", err)
+		}
+
+		var os, ps []goqu.Record
+
+		for _, o := range cc.Organizations {
+			o.ChannelConfigId = cc.Config.Id
+			os = append(os, channelOrganizationRecord(o))
+		}
+
+		for _, cp := range cc.Policies {
+			cp.ChannelConfigId = cc.Config.Id
+			ps = append(ps, channelPolicyRecord(cp))
+		}
+
+		err = insertAllTx(ctx, txx, channelOrganization, os)
+		if err != nil {
+			return fmt.Errorf("add channel organizations to DB: %w", err)
+		}
+
+		err = insertAllTx(ctx, txx, channelPolicy, ps)
+		if err != nil {
+			return fmt.Errorf("add channel policies to DB: %w", err)
+		}
+	}
+
+	for _, c := range b.Chaincodes {
+		c.Id, err = addTx(ctx, txx, chaincode,
+			goqu.Ex{"name": c.Name, "version": c.Version},
+			goqu.Record{"name": c.Name, "version": c.Version})
+		if err != nil {
+			return fmt.Errorf("add chaincode to DB: %w", err)
+		}
+
+		_, err = txx.Insert(channelChaincode).
+			Rows(goqu.Record{
+				"channel_id":   channelID,
+				"chaincode_id": c.Id,
+			}).
+			OnConflict(goqu.DoNothing()).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("add channel chaincode to DB: %w", err)
+		}
+	}
+
+	b.Block.ChannelId = channelID
+	b.Block.Id, err = addTx(ctx, txx, block, goqu.Ex{
+		"channel_id": channelID,
+		"number":     b.Block.Number,
+	}, b.Block)
+	if err != nil {
+		return fmt.Errorf("add block to DB: %w", err)
+	}
+
+	if b.RawBlock != nil {
+		b.RawBlock.ChannelId = channelID
+		_, err = txx.Insert(blockRaw).
+			Rows(rawBlockRecord(b.RawBlock)).
+			OnConflict(goqu.DoNothing()).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("add raw block to DB: %w", err)
+		}
+	}
+
+	for _, s := range b.Signers {
+		signerID, err := addIdentityTx(ctx, txx, s)
+		if err != nil {
+			return fmt.Errorf("add block signer identity to DB: %w", err)
+		}
+		_, err = txx.Insert(blockSignature).
+			Rows(goqu.Record{
+				"block_id":    b.Block.Id,
+				"identity_id": signerID,
+			}).
+			OnConflict(goqu.DoNothing()).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("add block signature to DB: %w", err)
+		}
+	}
+
+	err = setLastBlockNumberTx(ctx, txx, channelID, b.Block.Number)
+	if err != nil {
+		return fmt.Errorf("set last block number in DB: %w", err)
+	}
+
+	for i, t := range b.Transactions {
+		if b.Creators[i] != nil {
+			t.CreatorId, err = addIdentityTx(ctx, txx, b.Creators[i])
+			if err != nil {
+				return fmt.Errorf("add identity to DB: %w", err)
+			}
+		}
+		t.ChannelId = channelID
+		t.BlockId = b.Block.Id
+		_, err = txx.Insert(transaction).
+			Rows(transactionRecord(t)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("add transaction to DB: %w", err)
+		}
+	}
+
+	var (
+		arguments       []goqu.Record
+		endorsements    []goqu.Record
+		reads           []goqu.Record
+		writeHashes     []goqu.Record
+		writes          []goqu.Record
+		chaincodeEvents []goqu.Record
+	)
+
+	for _, a := range b.Arguments {
+		a.BlockId = b.Block.Id
+		arguments = append(arguments, argumentRecord(a))
+	}
+
+	for _, en := range b.Endorsements {
+		en.BlockId = b.Block.Id
+		endorsements = append(endorsements, endorsementRecord(en))
+	}
+
+	for _, r := range b.Reads {
+		r.BlockId = b.Block.Id
+		reads = append(reads, readRecord(r))
+	}
+
+	for _, rq := range b.RangeQueries {
+		rq.RangeQuery.BlockId = b.Block.Id
+		rq.RangeQuery.Id, err = insertTx(ctx, txx, rangeQuery,
+			rangeQueryRecord(rq.RangeQuery))
+		if err != nil {
+			return fmt.Errorf("add range query to DB: %w", err)
+		}
+		for _, r := range rq.Reads {
+			r.BlockId = b.Block.Id
+			r.RangeQueryId = rq.RangeQuery.Id
+			reads = append(reads, readRecord(r))
+		}
+	}
+
+	for _, pwh := range b.PrivateWriteHashes {
+		pwh.ChannelId = channelID
+		writeHashes = append(writeHashes, privateWriteHashRecord(pwh))
+	}
+
+	for _, pw := range b.PrivateWrites {
+		pw.ChannelId = channelID
+		writes = append(writes, privateWriteRecord(pw))
+	}
+
+	for _, ce := range b.ChaincodeEvents {
+		ce.ChannelId = channelID
+		chaincodeEvents = append(chaincodeEvents, chaincodeEventRecord(ce))
+	}
+
+	for _, i := range []struct {
+		table string
+		rows  []goqu.Record
+	}{
+		{argument, arguments},
+		{endorsement, endorsements},
+		{read, reads},
+		{privateWriteHash, writeHashes},
+		{privateWrite, writes},
+		{chaincodeEvent, chaincodeEvents},
+	} {
+		err = insertAllTx(ctx, txx, i.table, i.rows)
+		if err != nil {
+			return fmt.Errorf("add %s rows to DB: %w", i.table, err)
+		}
+	}
+
+	for _, s := range b.States {
+		s.State.ChannelId = channelID
+		err = writeStateTx(ctx, txx, s)
+		if err != nil {
+			return fmt.Errorf("write state to DB: %w", err)
+		}
+	}
+
+	for _, cdc := range b.ChaincodeDefinitions {
+		cdc.Definition.ChannelId = channelID
+		cdc.Definition.Id, err = addChaincodeDefinitionTx(ctx, txx,
+			cdc.Definition)
+		if err != nil {
+			return fmt.Errorf("add chaincode definition to DB: %w", err)
+		}
+		if cdc.Approval != nil {
+			cdc.Approval.ChaincodeDefinitionId = cdc.Definition.Id
+			_, err = insertTx(ctx, txx, chaincodeApproval,
+				chaincodeApprovalRecord(cdc.Approval))
+			if err != nil {
+				return fmt.Errorf("add chaincode approval to DB: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func addIdentityTx(ctx context.Context, txx *goqu.TxDatabase,
+	i *explorer.Identity) (int64, error) {
+
+	return addTx(ctx, txx, identity, goqu.Ex{"fingerprint": i.Fingerprint},
+		identityRecord(i))
+}
+
+// stateColumns are columns of state copied to old state when state changes.
+var stateColumns = []interface{}{"key", "chaincode", "channel_id",
+	"transaction_id", "type", "raw_value", "value", "created_at",
+	"version_block_number", "version_tx_number"}
+
+// stateVersion is a version of actual state or the last deletion of a key.
+type stateVersion struct {
+	BlockNumber int64 `db:"version_block_number"`
+	TxNumber    int64 `db:"version_tx_number"`
+}
+
+// writeStateTx moves actual state of the key to old states and replaces it
+// with the written state. Deletion is recorded as deleted old state. Write
+// older than actual state or the last deletion of the key, e.g. write of
+// retried failed block, is recorded as old state only.
+func writeStateTx(ctx context.Context, txx *goqu.TxDatabase,
+	s hf.StateWrite) error {
+
+	key := goqu.Ex{
+		"channel_id": s.State.ChannelId,
+		"chaincode":  s.State.Chaincode,
+		"key":        s.State.Key,
+	}
+
+	var actual struct {
+		Type        string `db:"type"`
+		BlockNumber int64  `db:"version_block_number"`
+		TxNumber    int64  `db:"version_tx_number"`
+	}
+
+	exists, err := txx.From(state).
+		Select("type", "version_block_number", "version_tx_number").
+		Where(key).
+		ScanStructContext(ctx, &actual)
+	if err != nil {
+		return fmt.Errorf("get actual state: %w", err)
+	}
+
+	outdated := exists && s.Precedes(actual.BlockNumber, actual.TxNumber)
+
+	if !outdated {
+		var deleted stateVersion
+
+		found, err := txx.From(oldState).
+			Select("version_block_number", "version_tx_number").
+			Where(key, goqu.Ex{"deleted": true}).
+			Order(goqu.C("version_block_number").Desc(),
+				goqu.C("version_tx_number").Desc()).
+			Limit(1).
+			ScanStructContext(ctx, &deleted)
+		if err != nil {
+			return fmt.Errorf("get last deleted state: %w", err)
+		}
+
+		outdated = found && s.Precedes(deleted.BlockNumber, deleted.TxNumber)
+	}
+
+	if outdated {
+		r := stateRecord(s.State)
+		if s.IsDelete {
+			r = deletedStateRecord(s.State, s.State.Type)
+		}
+		_, err = txx.Insert(oldState).
+			Rows(r).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("insert outdated state: %w", err)
+		}
+		return nil
+	}
+
+	typ := actual.Type
+
+	if exists {
+		_, err = txx.Insert(oldState).
+			Cols(stateColumns...).
+			FromQuery(txx.From(state).Select(stateColumns...).Where(key)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("insert old state: %w", err)
+		}
+
+		_, err = txx.Delete(state).
+			Where(key).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("delete actual state: %w", err)
+		}
+	}
+
+	if s.IsDelete {
+		if !exists {
+			typ = s.State.Type
+		}
+		_, err = txx.Insert(oldState).
+			Rows(deletedStateRecord(s.State, typ)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("insert deleted state: %w", err)
+		}
+		return nil
+	}
+
+	_, err = txx.Insert(state).
+		Rows(stateRecord(s.State)).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("insert actual state: %w", err)
+	}
+
+	return nil
+}
+
+// addChaincodeDefinitionTx adds chaincode definition if it is not exists
+// yet. Definitions are keyed by their parameters hash, so approvals of
+// different parameters of the same sequence are stored for different
+// definitions and only matching approvals are stored for committed one.
+func addChaincodeDefinitionTx(ctx context.Context, txx *goqu.TxDatabase,
+	cd *explorer.ChaincodeDefinition) (id int64, err error) {
+
+	exists, err := txx.Select("id").
+		From(chaincodeDefinition).
+		Where(goqu.Ex{
+			"channel_id":      cd.ChannelId,
+			"name":            cd.Name,
+			"sequence":        cd.Sequence,
+			"parameters_hash": cd.ParametersHash,
+		}).
+		ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, fmt.Errorf("get chaincode definition from DB: %w", err)
+	}
+	if exists && cd.TransactionId == "" {
+		return id, nil
+	}
+
+	if exists {
+		_, err = txx.Update(chaincodeDefinition).
+			Set(chaincodeDefinitionRecord(cd)).
+			Where(goqu.Ex{"id": id}).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("update chaincode definition in DB: %w", err)
+		}
+		return id, nil
+	}
+
+	id, err = insertTx(ctx, txx, chaincodeDefinition,
+		chaincodeDefinitionRecord(cd))
+	if err != nil {
+		return 0, fmt.Errorf("add chaincode definition to DB: %w", err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"testing"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/sirupsen/logrus"
+
+	"explorer/hf"
+	"explorer/hf/storagetest"
+)
+
+const testChannel = "mychannel"
+
+// testStorage returns storage of migrated shared in-memory database, which
+// exists until the end of test.
+func testStorage(t *testing.T) (hf.Storage, storagetest.StatesFunc) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+
+	sqlDB, err := sql.Open(driverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	// Connection is kept open during migration, since shared in-memory
+	// database exists while it has connections.
+	err = sqlDB.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrateDB, err := sql.Open(driverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = backend{}.Migrate(migrateDB)
+	migrateDB.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := goqu.New(sqliteDialect, sqlDB)
+
+	return backend{}.Storage(db, logrus.WithField("test", t.Name())),
+		dbStates(db)
+}
+
+// dbStates returns states of database. Values are stored as hex text, so
+// they are decoded.
+func dbStates(db *goqu.Database) storagetest.StatesFunc {
+	return func(t *testing.T, channelName, key string) (
+		*storagetest.State, []storagetest.State) {
+
+		t.Helper()
+
+		byKey := goqu.Ex{
+			"c.name":      channelName,
+			"s.chaincode": storagetest.Chaincode,
+			"s.key":       key,
+		}
+
+		var actual, old []storagetest.State
+
+		err := db.From(goqu.I(state).As("s")).
+			Join(goqu.I(channel).As("c"),
+				goqu.On(goqu.Ex{"s.channel_id": goqu.I("c.id")})).
+			Select("s.raw_value", "s.version_block_number",
+				"s.version_tx_number").
+			Where(byKey).
+			ScanStructs(&actual)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.From(goqu.I(oldState).As("s")).
+			Join(goqu.I(channel).As("c"),
+				goqu.On(goqu.Ex{"s.channel_id": goqu.I("c.id")})).
+			Select("s.raw_value", "s.deleted", "s.version_block_number",
+				"s.version_tx_number").
+			Where(byKey).
+			Order(goqu.I("s.id").Asc()).
+			ScanStructs(&old)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, ss := range [][]storagetest.State{actual, old} {
+			for i := range ss {
+				ss[i].Value = decodeHex(t, ss[i].Value)
+			}
+		}
+
+		if len(actual) == 0 {
+			return nil, old
+		}
+
+		return &actual[0], old
+	}
+}
+
+func decodeHex(t *testing.T, h []byte) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(string(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestStorageRetriedBlockStates(t *testing.T) {
+	s, states := testStorage(t)
+	storagetest.TestRetriedBlockStates(t, s, testChannel, states)
+}
+
+func TestStorageRawBlocks(t *testing.T) {
+	s, _ := testStorage(t)
+	storagetest.TestRawBlocks(t, s, testChannel)
+}
+
+func TestStorageResetChannel(t *testing.T) {
+	s, states := testStorage(t)
+	storagetest.TestResetChannel(t, s, testChannel, states)
+}
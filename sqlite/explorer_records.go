@@ -0,0 +1,301 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+
+	"explorer"
+)
+
+// Records of entities contain all table columns. Bytes are stored as hex
+// text like in postgres storage, except raw blocks data which is stored as
+// is.
+
+// textArray is a text array stored as JSON array in text column.
+type textArray []string
+
+func (a textArray) Value() (driver.Value, error) {
+	if a == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (a *textArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), (*[]string)(a))
+	case []byte:
+		return json.Unmarshal(v, (*[]string)(a))
+	default:
+		return fmt.Errorf("unexpected text array type %T", src)
+	}
+}
+
+// nullID returns nil for zero ID so it is stored as NULL reference.
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// nullBytes returns nil for empty bytes so they are stored as NULL.
+func nullBytes(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return hex.EncodeToString(b)
+}
+
+// nullJSON returns nil for empty JSON so it is stored as NULL.
+func nullJSON(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
+
+// blob returns blob literal of bytes.
+func blob(b []byte) exp.LiteralExpression {
+	return goqu.L("X'" + hex.EncodeToString(b) + "'")
+}
+
+func channelConfigRecord(cc *explorer.ChannelConfig) goqu.Record {
+	return goqu.Record{
+		"channel_id":                cc.ChannelId,
+		"raw":                       hex.EncodeToString(cc.Raw),
+		"parsed":                    string(cc.Parsed),
+		"created_at":                cc.CreatedAt.AsTime(),
+		"sequence":                  cc.Sequence,
+		"consensus_type":            cc.ConsensusType,
+		"batch_max_message_count":   cc.BatchMaxMessageCount,
+		"batch_absolute_max_bytes":  cc.BatchAbsoluteMaxBytes,
+		"batch_preferred_max_bytes": cc.BatchPreferredMaxBytes,
+		"batch_timeout":             cc.BatchTimeout,
+		"orderer_addresses":         textArray(cc.OrdererAddresses),
+	}
+}
+
+func channelOrganizationRecord(o *explorer.ChannelOrganization) goqu.Record {
+	return goqu.Record{
+		"channel_config_id":  o.ChannelConfigId,
+		"type":               o.Type,
+		"name":               o.Name,
+		"msp_id":             o.MspId,
+		"root_certs":         textArray(o.RootCerts),
+		"intermediate_certs": textArray(o.IntermediateCerts),
+		"tls_root_certs":     textArray(o.TlsRootCerts),
+		"anchor_peers":       textArray(o.AnchorPeers),
+		"orderer_endpoints":  textArray(o.OrdererEndpoints),
+	}
+}
+
+func channelPolicyRecord(cp *explorer.ChannelPolicy) goqu.Record {
+	return goqu.Record{
+		"channel_config_id": cp.ChannelConfigId,
+		"path":              cp.Path,
+		"name":              cp.Name,
+		"type":              cp.Type,
+		"rule":              cp.Rule,
+		"mod_policy":        cp.ModPolicy,
+	}
+}
+
+func rawBlockRecord(rb *explorer.RawBlock) goqu.Record {
+	return goqu.Record{
+		"channel_id":  rb.ChannelId,
+		"number":      rb.Number,
+		"compression": rb.Compression,
+		"source_url":  rb.SourceUrl,
+		"data":        blob(rb.Data),
+	}
+}
+
+func identityRecord(i *explorer.Identity) goqu.Record {
+	r := goqu.Record{
+		"msp_id":        i.MspId,
+		"fingerprint":   i.Fingerprint,
+		"subject":       i.Subject,
+		"issuer":        i.Issuer,
+		"serial_number": i.SerialNumber,
+		"not_after":     nil,
+	}
+	if i.NotAfter != nil {
+		r["not_after"] = i.NotAfter.AsTime()
+	}
+	return r
+}
+
+func transactionRecord(t *explorer.Transaction) goqu.Record {
+	return goqu.Record{
+		"id":              t.Id,
+		"channel_id":      t.ChannelId,
+		"block_id":        t.BlockId,
+		"number":          t.Number,
+		"created_at":      t.CreatedAt.AsTime(),
+		"validation_code": t.ValidationCode,
+		"creator_id":      nullID(t.CreatorId),
+		"function":        t.Function,
+		"type":            t.Type,
+		"raw_payload":     nullBytes(t.RawPayload),
+	}
+}
+
+func argumentRecord(a *explorer.Argument) goqu.Record {
+	return goqu.Record{
+		"transaction_id":     a.TransactionId,
+		"block_id":           a.BlockId,
+		"transaction_number": a.TransactionNumber,
+		"index":              a.Index,
+		"type":               a.Type,
+		"raw_value":          hex.EncodeToString(a.RawValue),
+		"value":              nullJSON(a.Value),
+	}
+}
+
+func endorsementRecord(en *explorer.Endorsement) goqu.Record {
+	return goqu.Record{
+		"transaction_id":     en.TransactionId,
+		"block_id":           en.BlockId,
+		"transaction_number": en.TransactionNumber,
+		"msp_id":             en.MspId,
+		"subject":            en.Subject,
+		"fingerprint":        en.Fingerprint,
+	}
+}
+
+func rangeQueryRecord(rq *explorer.RangeQuery) goqu.Record {
+	return goqu.Record{
+		"transaction_id":     rq.TransactionId,
+		"block_id":           rq.BlockId,
+		"transaction_number": rq.TransactionNumber,
+		"chaincode":          rq.Chaincode,
+		"start_key":          rq.StartKey,
+		"end_key":            rq.EndKey,
+		"itr_exhausted":      rq.ItrExhausted,
+	}
+}
+
+func readRecord(r *explorer.Read) goqu.Record {
+	return goqu.Record{
+		"transaction_id":       r.TransactionId,
+		"block_id":             r.BlockId,
+		"transaction_number":   r.TransactionNumber,
+		"range_query_id":       nullID(r.RangeQueryId),
+		"chaincode":            r.Chaincode,
+		"key":                  r.Key,
+		"has_version":          r.HasVersion,
+		"version_block_number": r.VersionBlockNumber,
+		"version_tx_number":    r.VersionTxNumber,
+	}
+}
+
+func privateWriteHashRecord(pwh *explorer.PrivateWriteHash) goqu.Record {
+	return goqu.Record{
+		"channel_id":     pwh.ChannelId,
+		"transaction_id": pwh.TransactionId,
+		"chaincode":      pwh.Chaincode,
+		"collection":     pwh.Collection,
+		"key_hash":       hex.EncodeToString(pwh.KeyHash),
+		"value_hash":     hex.EncodeToString(pwh.ValueHash),
+		"is_delete":      pwh.IsDelete,
+		"created_at":     pwh.CreatedAt.AsTime(),
+	}
+}
+
+func privateWriteRecord(pw *explorer.PrivateWrite) goqu.Record {
+	return goqu.Record{
+		"channel_id":     pw.ChannelId,
+		"transaction_id": pw.TransactionId,
+		"chaincode":      pw.Chaincode,
+		"collection":     pw.Collection,
+		"key":            pw.Key,
+		"type":           pw.Type,
+		"raw_value":      hex.EncodeToString(pw.RawValue),
+		"value":          nullJSON(pw.Value),
+		"is_delete":      pw.IsDelete,
+		"created_at":     pw.CreatedAt.AsTime(),
+	}
+}
+
+func chaincodeEventRecord(ce *explorer.ChaincodeEvent) goqu.Record {
+	return goqu.Record{
+		"channel_id":     ce.ChannelId,
+		"transaction_id": ce.TransactionId,
+		"chaincode":      ce.Chaincode,
+		"name":           ce.Name,
+		"type":           ce.Type,
+		"raw_payload":    hex.EncodeToString(ce.RawPayload),
+		"payload":        nullJSON(ce.Payload),
+		"created_at":     ce.CreatedAt.AsTime(),
+	}
+}
+
+func chaincodeDefinitionRecord(cd *explorer.ChaincodeDefinition) goqu.Record {
+	r := goqu.Record{
+		"channel_id":         cd.ChannelId,
+		"name":               cd.Name,
+		"sequence":           cd.Sequence,
+		"version":            cd.Version,
+		"endorsement_plugin": cd.EndorsementPlugin,
+		"validation_plugin":  cd.ValidationPlugin,
+		"init_required":      cd.InitRequired,
+		"parameters_hash":    cd.ParametersHash,
+	}
+	if len(cd.EndorsementPolicy) > 0 {
+		r["endorsement_policy"] = string(cd.EndorsementPolicy)
+	}
+	if len(cd.Collections) > 0 {
+		r["collections"] = string(cd.Collections)
+	}
+	if cd.TransactionId != "" {
+		r["transaction_id"] = cd.TransactionId
+		r["committed_at"] = cd.CommittedAt.AsTime()
+	}
+	return r
+}
+
+func chaincodeApprovalRecord(ca *explorer.ChaincodeApproval) goqu.Record {
+	return goqu.Record{
+		"chaincode_definition_id": ca.ChaincodeDefinitionId,
+		"transaction_id":          ca.TransactionId,
+		"msp_id":                  ca.MspId,
+		"created_at":              ca.CreatedAt.AsTime(),
+	}
+}
+
+func stateRecord(s *explorer.State) goqu.Record {
+	return goqu.Record{
+		"key":            s.Key,
+		"chaincode":      s.Chaincode,
+		"channel_id":     s.ChannelId,
+		"transaction_id": s.TransactionId,
+		"type":           s.Type,
+		"raw_value":      hex.EncodeToString(s.RawValue),
+		"value":          nullJSON(s.Value),
+		"created_at":     s.CreatedAt.AsTime(),
+
+		"version_block_number": s.VersionBlockNumber,
+		"version_tx_number":    s.VersionTxNumber,
+	}
+}
+
+func deletedStateRecord(s *explorer.State, typ string) goqu.Record {
+	r := stateRecord(s)
+	r["type"] = typ
+	r["deleted"] = true
+	return r
+}
@@ -0,0 +1,26 @@
+drop table chaincode_approval;
+drop table chaincode_definition;
+drop table chaincode_event;
+drop table private_write;
+drop table private_write_hash;
+drop table old_state;
+drop table state;
+drop table read;
+drop table range_query;
+drop table endorsement;
+drop table argument;
+drop table "transaction";
+drop table block_signature;
+drop table identity;
+drop table checkpoint;
+drop table failed_block;
+drop table block_raw;
+drop table block;
+drop table channel_chaincode;
+drop table chaincode;
+drop table channel_policy;
+drop table channel_organization;
+drop table channel_config;
+drop table peer_channel;
+drop table channel;
+drop table peer;
@@ -0,0 +1,345 @@
+create table peer (
+    id integer primary key autoincrement,
+    url text not null unique
+);
+
+create table channel (
+    id integer primary key autoincrement,
+    name text not null unique
+);
+
+create table peer_channel (
+    peer_id integer not null references peer(id),
+    channel_id integer not null references channel(id),
+    unique (peer_id, channel_id)
+);
+
+create table channel_config (
+    id integer primary key autoincrement,
+    channel_id integer not null references channel(id),
+    raw blob not null,
+    parsed text not null,
+    created_at timestamp not null,
+    sequence integer not null default 0,
+    consensus_type text not null default '',
+    batch_max_message_count integer not null default 0,
+    batch_absolute_max_bytes integer not null default 0,
+    batch_preferred_max_bytes integer not null default 0,
+    batch_timeout text not null default '',
+    orderer_addresses text not null default '[]'
+);
+
+create table channel_organization (
+    id integer primary key autoincrement,
+    channel_config_id integer not null references channel_config(id),
+    type text not null,
+    name text not null,
+    msp_id text not null,
+    root_certs text not null,
+    intermediate_certs text not null,
+    tls_root_certs text not null,
+    anchor_peers text not null,
+    orderer_endpoints text not null
+);
+
+create index channel_organization_channel_config_id_idx
+    on channel_organization (channel_config_id);
+create index channel_organization_msp_id_idx on channel_organization (msp_id);
+
+create table channel_policy (
+    id integer primary key autoincrement,
+    channel_config_id integer not null references channel_config(id),
+    path text not null,
+    name text not null,
+    type text not null,
+    rule text not null,
+    mod_policy text not null
+);
+
+create index channel_policy_channel_config_id_path_idx
+    on channel_policy (channel_config_id, path);
+
+create table chaincode (
+    id integer primary key autoincrement,
+    name text not null,
+    version text not null,
+    unique (name, version)
+);
+
+create table channel_chaincode (
+    channel_id integer not null references channel(id),
+    chaincode_id integer not null references chaincode(id),
+    unique (channel_id, chaincode_id)
+);
+
+create table block (
+    id integer primary key autoincrement,
+    channel_id integer not null references channel(id),
+    number integer not null,
+    data_hash text not null default '',
+    previous_hash text not null default '',
+    hash text not null default '',
+    transaction_count integer not null default 0,
+    size integer not null default 0,
+    last_config_index integer not null default 0,
+    commit_hash text not null default '',
+    unique (channel_id, number)
+);
+
+create index block_hash_idx on block (hash);
+
+create table block_raw (
+    channel_id integer not null references channel(id),
+    number integer not null,
+    compression text not null,
+    source_url text not null,
+    data blob not null,
+    primary key (channel_id, number)
+);
+
+create table failed_block (
+    id integer primary key autoincrement,
+    channel_id integer not null references channel(id),
+    number integer not null,
+    compression text not null,
+    source_url text not null,
+    data blob not null,
+    error text not null,
+    failures integer not null,
+    created_at timestamp not null default current_timestamp,
+    unique (channel_id, number)
+);
+
+create table checkpoint (
+    channel_id integer primary key references channel(id),
+    block_number integer not null
+);
+
+create table identity (
+    id integer primary key autoincrement,
+    msp_id text not null,
+    fingerprint text not null unique,
+    subject text not null,
+    issuer text not null,
+    serial_number text not null,
+    not_after timestamp
+);
+
+create index identity_msp_id_idx on identity (msp_id);
+
+create table block_signature (
+    block_id integer not null references block(id),
+    identity_id integer not null references identity(id),
+    primary key (block_id, identity_id)
+);
+
+create index block_signature_identity_id_idx on block_signature (identity_id);
+
+create table "transaction" (
+    id text not null,
+    channel_id integer not null references channel(id),
+    block_id integer not null references block(id),
+    number integer not null,
+    created_at timestamp not null,
+    validation_code text not null,
+    creator_id integer references identity(id),
+    function text not null default '',
+    type text not null default '',
+    raw_payload blob,
+    primary key (block_id, number)
+);
+
+create index transaction_id_idx on "transaction" (id);
+create index transaction_channel_id_created_at_idx
+    on "transaction" (channel_id, created_at);
+create index transaction_validation_code_idx on "transaction" (validation_code);
+create index transaction_creator_id_idx on "transaction" (creator_id);
+create index transaction_function_idx on "transaction" (function);
+create index transaction_type_idx on "transaction" (type);
+
+create table argument (
+    id integer primary key autoincrement,
+    transaction_id text not null,
+    block_id integer not null,
+    transaction_number integer not null,
+    "index" integer not null,
+    type text not null,
+    raw_value blob not null,
+    value text,
+    foreign key (block_id, transaction_number)
+        references "transaction"(block_id, number)
+);
+
+create index argument_transaction_id_idx on argument (transaction_id);
+create index argument_block_id_transaction_number_idx
+    on argument (block_id, transaction_number);
+
+create table endorsement (
+    id integer primary key autoincrement,
+    transaction_id text not null,
+    block_id integer not null,
+    transaction_number integer not null,
+    msp_id text not null,
+    subject text not null,
+    fingerprint text not null,
+    foreign key (block_id, transaction_number)
+        references "transaction"(block_id, number)
+);
+
+create index endorsement_transaction_id_idx on endorsement (transaction_id);
+create index endorsement_block_id_transaction_number_idx
+    on endorsement (block_id, transaction_number);
+create index endorsement_msp_id_idx on endorsement (msp_id);
+
+create table range_query (
+    id integer primary key autoincrement,
+    transaction_id text not null,
+    block_id integer not null,
+    transaction_number integer not null,
+    chaincode text not null,
+    start_key text not null,
+    end_key text not null,
+    itr_exhausted boolean not null,
+    foreign key (block_id, transaction_number)
+        references "transaction"(block_id, number)
+);
+
+create index range_query_transaction_id_idx on range_query (transaction_id);
+create index range_query_block_id_transaction_number_idx
+    on range_query (block_id, transaction_number);
+
+create table read (
+    id integer primary key autoincrement,
+    transaction_id text not null,
+    block_id integer not null,
+    transaction_number integer not null,
+    range_query_id integer references range_query(id),
+    chaincode text not null,
+    key text not null,
+    has_version boolean not null,
+    version_block_number integer not null,
+    version_tx_number integer not null,
+    foreign key (block_id, transaction_number)
+        references "transaction"(block_id, number)
+);
+
+create index read_transaction_id_idx on read (transaction_id);
+create index read_block_id_transaction_number_idx
+    on read (block_id, transaction_number);
+
+create table state (
+    channel_id integer not null references channel(id),
+    chaincode text not null,
+    key text not null,
+    transaction_id text not null,
+    type text not null,
+    raw_value blob not null,
+    value text,
+    created_at timestamp not null,
+    version_block_number integer not null,
+    version_tx_number integer not null,
+    primary key (channel_id, chaincode, key)
+);
+
+create table old_state (
+    id integer primary key autoincrement,
+    channel_id integer not null references channel(id),
+    chaincode text not null,
+    key text not null,
+    transaction_id text not null,
+    type text not null,
+    raw_value blob not null,
+    value text,
+    created_at timestamp not null,
+    deleted boolean not null default 0,
+    version_block_number integer not null,
+    version_tx_number integer not null
+);
+
+create index old_state_channel_id_chaincode_key_idx
+    on old_state (channel_id, chaincode, key);
+
+create index old_state_deleted_version_idx
+    on old_state (channel_id, chaincode, key, version_block_number,
+        version_tx_number) where deleted;
+
+create table private_write_hash (
+    id integer primary key autoincrement,
+    channel_id integer not null references channel(id),
+    transaction_id text not null,
+    chaincode text not null,
+    collection text not null,
+    key_hash blob not null,
+    value_hash blob not null,
+    is_delete boolean not null,
+    created_at timestamp not null
+);
+
+create index private_write_hash_transaction_id_idx
+    on private_write_hash (transaction_id);
+create index private_write_hash_channel_id_chaincode_collection_idx
+    on private_write_hash (channel_id, chaincode, collection);
+
+create table private_write (
+    id integer primary key autoincrement,
+    channel_id integer not null references channel(id),
+    transaction_id text not null,
+    chaincode text not null,
+    collection text not null,
+    key text not null,
+    type text not null,
+    raw_value blob not null,
+    value text,
+    is_delete boolean not null,
+    created_at timestamp not null
+);
+
+create index private_write_transaction_id_idx on private_write (transaction_id);
+create index private_write_channel_id_chaincode_collection_key_idx
+    on private_write (channel_id, chaincode, collection, key);
+
+create table chaincode_event (
+    id integer primary key autoincrement,
+    channel_id integer not null references channel(id),
+    transaction_id text not null,
+    chaincode text not null,
+    name text not null,
+    type text not null,
+    raw_payload blob not null,
+    payload text,
+    created_at timestamp not null
+);
+
+create index chaincode_event_transaction_id_idx
+    on chaincode_event (transaction_id);
+create index chaincode_event_channel_id_chaincode_name_created_at_idx
+    on chaincode_event (channel_id, chaincode, name, created_at);
+
+create table chaincode_definition (
+    id integer primary key autoincrement,
+    channel_id integer not null references channel(id),
+    name text not null,
+    sequence integer not null,
+    version text not null,
+    endorsement_plugin text not null,
+    validation_plugin text not null,
+    endorsement_policy text,
+    collections text,
+    init_required boolean not null,
+    transaction_id text,
+    committed_at timestamp,
+    parameters_hash text not null default '',
+    unique (channel_id, name, sequence, parameters_hash)
+);
+
+create table chaincode_approval (
+    id integer primary key autoincrement,
+    chaincode_definition_id integer not null
+        references chaincode_definition(id),
+    transaction_id text not null,
+    msp_id text not null,
+    created_at timestamp not null
+);
+
+create index chaincode_approval_chaincode_definition_id_idx
+    on chaincode_approval (chaincode_definition_id);